@@ -0,0 +1,111 @@
+package chandymisra
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+)
+
+// Link is one Philosopher's view of an edge of the conflict graph: the neighbor at the other end, the Fork they share,
+// and whether this end currently holds the request token for the Fork.
+type Link struct {
+	Neighbor int
+	Fork     *Fork
+	Request  bool
+}
+
+// Diner carries out the rules R1-R4 of the C&M paper for one philosopher, over its links to its neighbors:
+// https://www.cs.utexas.edu/users/misra/scannedPdf.dir/DrinkingPhil.pdf
+//
+// A dining Philosopher is a Diner with two links, to its left and right. A drinking philosopher is a Diner linked to
+// every neighbor on its conflict graph, which keeps its diners state apart from the state it shows.
+type Diner struct {
+	*shared.PhilosopherBase
+	Self  shared.Philosopher // The philosopher the Diner is part of, as its neighbors know it
+	Links []*Link
+}
+
+// Find the link carrying fork f
+func (d *Diner) linkFor(f shared.Fork) *Link {
+	for _, l := range d.Links {
+		if l.Fork == f {
+			return l
+		}
+	}
+	panic(fmt.Sprintf("fork %d is not shared with philosopher %d", f.GetID(), d.ID))
+}
+
+// Forks returns the forks on all my links. The links never change once the Diner is created, so this is safe to call
+// from any goroutine - though only the holders of the forks can be relied on there
+func (d *Diner) Forks() []shared.Fork {
+	forks := []shared.Fork{}
+	for _, l := range d.Links {
+		forks = append(forks, l.Fork)
+	}
+	return forks
+}
+
+// HoldsAllForks returns true if I hold the fork on every link
+func (d *Diner) HoldsAllForks() bool {
+	for _, l := range d.Links {
+		if !l.Fork.IsHeldBy(d.ID) {
+			return false
+		}
+	}
+	return true
+}
+
+// DirtyForks dirties the fork on every link, as eating does, checking that I hold them all
+func (d *Diner) DirtyForks() {
+	for _, l := range d.Links {
+		d.Check(l.Fork.IsHeldBy(d.ID), []int{l.Fork.ID}, "eating without holding fork %d", l.Fork.ID)
+		l.Fork.Dirty = true
+	}
+}
+
+// ReceiveFork carries out C&M (R4) - receive a fork
+func (d *Diner) ReceiveFork(m ForkMessage) {
+	f := d.linkFor(m.Fork).Fork
+	d.Check(!f.IsHeld(), []int{f.ID}, "fork %d already held by %d", f.ID, f.Holder())
+	d.WriteString(fmt.Sprintf("receives fork %d", f.ID))
+	f.SetHolder(d.ID)
+	d.EmitPeer(shared.ForkReceived, m.Sender.GetID(), f.ID)
+}
+
+// ReceiveRequest carries out C&M (R3) - receive a fork request
+func (d *Diner) ReceiveRequest(m ForkRequestMessage) {
+	l := d.linkFor(m.Fork)
+	d.Check(!l.Request, []int{l.Fork.ID}, "fork %d has already been requested", l.Fork.ID)
+	d.WriteString(fmt.Sprintf("received fork request for %d", l.Fork.ID))
+	l.Request = true
+	d.EmitPeer(shared.ForkRequestReceived, m.Requester.GetID(), l.Fork.ID)
+}
+
+// SendForks carries out C&M (R2) and (R1) on every link, for a Diner in state s - sending any fork that has been
+// requested and isn't needed, and requesting any fork that is
+func (d *Diner) SendForks(s philstate.Enum) {
+	for _, l := range d.Links {
+		f := l.Fork
+		neighbor := d.Table.Philosophers[l.Neighbor]
+
+		if s != philstate.Eating && l.Request && f.IsHeldBy(d.ID) && (f.Dirty || s != philstate.Hungry) {
+			// C&M (R2): I'm done eating and someone has requested a fork - free it (and clean it) then send it over.
+			// A fork changes hands without changing who has precedence, so a clean fork - held by a drinking
+			// philosopher whose session ended before it got to eat - goes over dirty
+			f.Dirty = !f.Dirty
+			f.SetFree()
+			d.EmitPeer(shared.ForkSent, l.Neighbor, f.ID)
+			d.Send(neighbor, ForkMessage{Sender: d.Self, Fork: f})
+			d.WriteString(fmt.Sprintf("sent fork %d to philosopher %d", f.ID, l.Neighbor))
+		}
+
+		// This must come after R2 - if I was hungry, but had to give up a dirty fork, I need to ask for it back
+		if s == philstate.Hungry && l.Request && !f.IsHeldBy(d.ID) {
+			// C&M (R1): I'm hungry and I need a fork - request it from the neighbor
+			l.Request = false
+			d.EmitPeer(shared.ForkRequested, l.Neighbor, f.ID)
+			d.Send(neighbor, ForkRequestMessage{Requester: d.Self, Fork: f})
+			d.WriteString(fmt.Sprintf("requested fork %d", f.ID))
+		}
+	}
+}
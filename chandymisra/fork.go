@@ -7,8 +7,3 @@ type Fork struct {
 	*shared.ForkBase
 	Dirty bool
 }
-
-// Convert a Fork interface to my implementation
-func asFork(f shared.Fork) *Fork {
-	return f.(*Fork)
-}
@@ -13,5 +13,5 @@ type ForkMessage struct {
 
 // String implements the Stringer interface
 func (m ForkMessage) String() string {
-	return fmt.Sprintf("Philosopher %d sends fork %d", asPhilosopher(m.Sender).ID, asFork(m.Fork).ID)
+	return fmt.Sprintf("Philosopher %d sends fork %d", m.Sender.GetID(), m.Fork.GetID())
 }
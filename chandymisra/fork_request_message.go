@@ -13,5 +13,5 @@ type ForkRequestMessage struct {
 
 // String implements the Stringer interface
 func (m ForkRequestMessage) String() string {
-	return fmt.Sprintf("Philosopher %d requests fork %d", asPhilosopher(m.Requester).ID, asFork(m.Fork).ID)
+	return fmt.Sprintf("Philosopher %d requests fork %d", m.Requester.GetID(), m.Fork.GetID())
 }
@@ -1,65 +1,20 @@
 package chandymisra
 
 import (
-	"fmt"
 	"github.com/wizardpb/diningphils-go/shared"
 	"github.com/wizardpb/diningphils-go/shared/philstate"
 )
 
-// Philosopher implementation
+// Philosopher implementation - a Diner linked to its left (index 0) and right (index 1) neighbors
 type Philosopher struct {
-	*shared.PhilosopherBase
-	// ForkRequest holds fork requests for left (index 0) and right (index 1) Forks (nil => not requested).
-	ForkRequest [2]bool
-}
-
-// Index of the fork request flag of fork f
-func (p *Philosopher) flagID(f shared.Fork) int {
-	switch {
-	case p.LeftFork() == f:
-		return 0
-	case p.RightFork() == f:
-		return 1
-	default:
-		panic("fork not left or right for flagID")
-	}
-}
-
-// Convert to my implementation
-func asPhilosopher(p shared.Philosopher) *Philosopher {
-	return p.(*Philosopher)
-}
-
-// Who should I ask for Fork f ?
-func (p *Philosopher) philosopherFor(f shared.Fork) shared.Philosopher {
-	switch {
-	case p.IsLeftFork(f):
-		return p.LeftPhilosopher()
-	case p.IsRightFork(f):
-		return p.RightPhilosopher()
-	default:
-		panic(fmt.Sprintf("Incorrect fork %d for philosopher %d", f.GetID(), p.ID))
-	}
-}
-
-// Set the request flag for Fork f
-func (p *Philosopher) setRequested(f shared.Fork, b bool) {
-	p.ForkRequest[p.flagID(f)] = b
-}
-
-// Do I have a request for Fork f?
-func (p *Philosopher) hasRequestFor(f shared.Fork) bool {
-	return p.ForkRequest[p.flagID(f)]
+	Diner
 }
 
 // Eat - check the invariants and dirty the forks before starting to eat
 func (p *Philosopher) Eat() {
 	p.CheckEating()
 	// Dirty the forks first...
-	for _, mf := range []*Fork{asFork(p.LeftFork()), asFork(p.RightFork())} {
-		shared.Assert(func() bool { return mf.IsHeldBy(p.ID) }, " eating without holding a fork")
-		mf.Dirty = true
-	}
+	p.DirtyForks()
 	p.PhilosopherBase.Eat()
 }
 
@@ -71,120 +26,98 @@ func (p *Philosopher) Execute(m shared.Message) {
 
 	case shared.NewState:
 		// Update our state value
-		p.State = mt.NewState
+		p.SetState(mt.NewState)
 		switch p.State {
 		case philstate.Hungry:
-			// No action here - taken care of below
-			break
+			// If nobody asked for my forks while I was thinking, I still hold both and can eat straight away.
+			// Otherwise there's no action here - taken care of below
+			if p.HoldsAllForks() {
+				p.WriteString("holds both forks and can eat")
+				p.Eat()
+			}
 		case philstate.Thinking:
 			p.PhilosopherBase.StartThinking()
 		}
 
 	case ForkMessage:
-		// C&M (R4) - receive a fork
-		f := asFork(mt.Fork)
-		shared.Assert(func() bool { return !f.IsHeld() }, "fork %d already held by %d", f.ID, f.Holder)
-		p.WriteString(fmt.Sprintf("receives fork %d", f.ID))
-		f.SetHolder(p.ID)
+		p.ReceiveFork(mt)
 
 		// If we have both forks we can now eat! Both forks will now be dirty
-		if p.LeftFork().IsHeldBy(p.ID) && p.RightFork().IsHeldBy(p.ID) {
-			p.WriteString(fmt.Sprintf("holds both forks and can eat"))
+		if p.HoldsAllForks() {
+			p.WriteString("holds both forks and can eat")
 			p.Eat()
 		}
 
 	case ForkRequestMessage:
-		//C&M (R3) - receive a fork request
-		shared.Assert(func() bool { return !p.hasRequestFor(mt.Fork) }, "fork %d has already been requested", mt.Fork.GetID())
-		p.WriteString(fmt.Sprintf("received fork request for %d", mt.Fork.GetID()))
-		p.setRequested(mt.Fork, true)
+		p.ReceiveRequest(mt)
 
 	default:
 		p.WriteString("unknown message: " + m.String())
 	}
 
-	// ... and then check for any implied message send.
-	// Fork each of my forks...
-	for _, f := range []shared.Fork{p.LeftFork(), p.RightFork()} {
-		mf := asFork(f)
-		switch {
+	// ... and then check for any implied message send
+	p.SendForks(p.State)
+}
 
-		case p.IsHungry() && p.hasRequestFor(f) && !f.IsHeldBy(p.ID):
-			// C&M (R1): I'm hungry and I need a fork - request it from the appropriate philosopher
-			p.setRequested(f, false)
-			p.philosopherFor(f).Messages() <- ForkRequestMessage{
-				Requester: p,
-				Fork:      f,
+// The key for the Table value holding the table's forks
+type forksKey struct{}
+
+// tableForks returns the Forks of Table t, dealing them out the first time they are asked for.
+//
+// Set up forks so the dependency graph is acyclic: phil 0 has both forks, phil 1 has none, the rest have the left
+// fork only. So fork 1 (phil 0's right fork) starts with phil 0, and every other fork with the phil to its right.
+func tableForks(t *shared.Table) []*Fork {
+	return t.Value(forksKey{}, func() interface{} {
+		forks := make([]*Fork, t.NPhils)
+		for i := range forks {
+			forks[i] = &Fork{
+				ForkBase: &shared.ForkBase{
+					ID: i,
+				},
+				Dirty: true, // All Forks start out dirty
 			}
-			p.WriteString(fmt.Sprintf("requested fork %d", f.GetID()))
-
-		case !p.IsEating() && p.hasRequestFor(f) && f.IsHeldBy(p.ID) && mf.Dirty:
-			// C&M (R2): I'm done eating and someone has requested a fork - free it (and clean it) then send it over
-			requestingPhilosopher := p.philosopherFor(f)
-			mf.Dirty = false
-			mf.SetFree()
-			requestingPhilosopher.Messages() <- ForkMessage{
-				Sender: p,
-				Fork:   f,
+			if i == 1 {
+				forks[i].SetHolder(0)
+			} else {
+				forks[i].SetHolder(i)
 			}
-			p.WriteString(fmt.Sprintf("sent fork %d to philosopher %d", f.GetID(), requestingPhilosopher.GetID()))
 		}
-	}
+		return forks
+	}).([]*Fork)
 }
 
-// Factory is the creation function for a Philosopher
-func Factory(params shared.CreateParams) (shared.Philosopher, shared.Fork) {
-	return &Philosopher{
-			PhilosopherBase: &shared.PhilosopherBase{
-				ID:         params.ID,
-				Name:       params.Name,
-				State:      philstate.Inactive,
-				ThinkRange: params.ThinkRange,
-				EatRange:   params.EatRange,
-				// We need a buffered channel here...
-				MessageChan: make(chan shared.Message, 10),
-			},
-			ForkRequest: [2]bool{false, false},
-		},
-		&Fork{
-			ForkBase: &shared.ForkBase{
-				ID:     params.ID,
-				Holder: shared.UnOwned,
-			},
-			Dirty: true, // All Forks start out dirty
-		}
-}
-
-// Start implements the Philosopher interface
-func (p *Philosopher) Start() {
-	/*
-	 * Set initial conditions:
-	 *
-	 * Set up forks so the dependency graph is acyclic: phil 0 has both forks, phil 1 has none,
-	 * the rest have the left fork only
-	 *
-	 * Request flags are set opposite this so that all philosophers can initially request the missing fork. The default
-	 * value is false so we only need to set the flag for the missing fork
-	 */
-
-	for _, p := range shared.Philosophers {
-		mp := asPhilosopher(p)
-		switch mp.ID {
-		case 0:
-			// Both request flags false
-			mp.LeftFork().SetHolder(mp.ID)
-			mp.RightFork().SetHolder(mp.ID)
-		case 1:
-			// Forks missing, set both request flags
-			mp.setRequested(mp.LeftFork(), true)
-			mp.setRequested(mp.RightFork(), true)
-		default:
-			// Hold the left fork, set the right request flag
-			mp.LeftFork().SetHolder(mp.ID)
-			mp.setRequested(mp.RightFork(), true)
-		}
+// Factory is the creation function for a Philosopher. The Fork returned is the one to its left, dealt out by
+// tableForks.
+//
+// Request flags are set opposite the forks so that all philosophers can initially request the missing fork.
+//
+// Doing this here, rather than in Start, means nobody touches another philosopher's forks or flags - every
+// philosopher starts in its own goroutine, and a neighbor may already be running.
+func Factory(t *shared.Table, params shared.CreateParams) (shared.Philosopher, shared.Fork) {
+	forks := tableForks(t)
+	n := t.NPhils
+	links := []*Link{
+		{Neighbor: (params.ID + n - 1) % n, Fork: forks[params.ID]},
+		{Neighbor: (params.ID + 1) % n, Fork: forks[(params.ID+1)%n]},
+	}
+	for _, l := range links {
+		l.Request = !l.Fork.IsHeldBy(params.ID)
 	}
 
-	// Then actually start
-	p.PhilosopherBase.Start()
+	p := &Philosopher{Diner{
+		PhilosopherBase: &shared.PhilosopherBase{
+			Table:      t,
+			ID:         params.ID,
+			Name:       params.Name,
+			State:      philstate.Inactive,
+			ThinkRange: params.ThinkRange,
+			EatRange:   params.EatRange,
+			Rand:       params.Rand,
+			// We need a buffered channel here...
+			MessageChan: make(chan shared.Message, 10),
+		},
+		Links: links,
+	}}
+	p.Self = p
+	return p, forks[params.ID]
 }
@@ -0,0 +1,96 @@
+package chandymisra_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/monitor"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"testing"
+)
+
+// Seat a table of n philosophers and start them, without running it - the test delivers their messages. A virtual
+// clock that is never run holds their timers
+func newTable(n int) *shared.Table {
+	table, _ := shared.NewTable(n, shared.DiscardOutput{})
+	table.Clock = shared.NewVirtualClock()
+	table.Seat(chandymisra.Factory)
+	for _, p := range table.Philosophers {
+		p.Start()
+	}
+	return table
+}
+
+// Take the messages waiting for philosopher id, describing each
+func drain(table *shared.Table, id int) []string {
+	messages := []string{}
+	for {
+		select {
+		case m := <-table.Philosophers[id].Messages():
+			messages = append(messages, m.String())
+		default:
+			return messages
+		}
+	}
+}
+
+// The request flags of philosopher id, for its left and right forks
+func requests(table *shared.Table, id int) [2]bool {
+	links := table.Philosophers[id].(*chandymisra.Philosopher).Links
+	return [2]bool{links[0].Request, links[1].Request}
+}
+
+func TestInitialForks(t *testing.T) {
+	table := newTable(5)
+	graph := monitor.NewPrecedenceGraph(table)
+	table.Sinks = append(table.Sinks, graph)
+
+	// Phil 0 has both forks, phil 1 has none, and the rest have their left fork. Each asks for the forks it is missing
+	holders := []int{}
+	for _, f := range table.Forks {
+		holders = append(holders, f.Holder())
+		assert.True(t, f.(*chandymisra.Fork).Dirty)
+	}
+	assert.Equal(t, []int{0, 0, 2, 3, 4}, holders)
+	assert.Equal(t, [2]bool{false, false}, requests(table, 0))
+	assert.Equal(t, [2]bool{true, true}, requests(table, 1))
+	for id := 2; id < 5; id++ {
+		assert.Equal(t, [2]bool{false, true}, requests(table, id))
+	}
+
+	// So the precedence graph starts out acyclic
+	for _, p := range table.Philosophers {
+		p.(*chandymisra.Philosopher).SetState(philstate.Thinking)
+	}
+	g := graph.Graph()
+	assert.Len(t, g.Edges, 5)
+	assert.Empty(t, g.Cycle)
+}
+
+func TestHungryWithBothForks(t *testing.T) {
+	table := newTable(3)
+
+	// Nobody has asked phil 0 for its forks, so it can eat as soon as it is hungry
+	p := table.Philosophers[0]
+	p.Execute(shared.NewState{NewState: philstate.Hungry})
+	assert.Equal(t, philstate.Eating, p.GetState())
+	assert.Empty(t, drain(table, 1))
+	assert.Empty(t, drain(table, 2))
+}
+
+func TestRequestAfterSending(t *testing.T) {
+	table := newTable(3)
+	p1, p2 := table.Philosophers[1], table.Philosophers[2]
+
+	// Phil 2 holds fork 2, and is hungry for fork 0...
+	p2.Execute(shared.NewState{NewState: philstate.Hungry})
+	assert.Equal(t, []string{"Philosopher 2 requests fork 0"}, drain(table, 0))
+
+	// ... when phil 1 asks it for fork 2. The fork is dirty, so phil 2 must give it up - and then ask for it back
+	p1.Execute(shared.NewState{NewState: philstate.Hungry})
+	m := <-p2.Messages()
+	assert.Equal(t, "Philosopher 1 requests fork 2", m.String())
+	p2.Execute(m)
+	assert.Equal(t, []string{"Philosopher 2 sends fork 2", "Philosopher 2 requests fork 2"}, drain(table, 1))
+	assert.Equal(t, philstate.Hungry, p2.GetState())
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared"
+	"os"
+	"strings"
+	"time"
+)
+
+// comparison is how one algorithm did in a comparison run
+type comparison struct {
+	Algorithm       string        `json:"algorithm"`
+	Reached         time.Duration `json:"reached"` // how far the run got - short of the duration if it stalled
+	Meals           int           `json:"meals"`
+	MealsPerHour    float64       `json:"meals_per_hour"`
+	MeanWait        time.Duration `json:"mean_wait"`
+	P99Wait         time.Duration `json:"p99_wait"`
+	Fairness        float64       `json:"fairness"`          // Jain's index over meals
+	MessagesPerMeal float64       `json:"messages_per_meal"` // not counting the philosophers' own timers
+	MaxEating       int           `json:"max_eating"`
+	Violations      int           `json:"violations"`
+	Unsafe          bool          `json:"unsafe"` // the safety monitor found two neighbors eating, or a fork shared
+}
+
+// compare runs one algorithm in virtual time for duration, at a table of n with the given seed
+func compare(impl implementation, n int, seed int64, duration, pickupDelay time.Duration) (comparison, error) {
+	t, err := shared.NewTable(n, shared.DiscardOutput{})
+	if err != nil {
+		return comparison{}, err
+	}
+	clock := shared.NewVirtualClock()
+	t.Clock = clock
+	t.Seed = seed
+	// Keep going whatever happens, so that every algorithm runs for the same time
+	t.Policy = shared.LogViolation
+	stats := shared.NewStats(t.Names)
+	t.Sinks = append(t.Sinks, stats)
+	safety := newSafetyMonitor(t, impl.name)
+	if safety != nil {
+		t.Sinks = append(t.Sinks, safety)
+	}
+
+	t.Seat(impl.factory(pickupDelay))
+	t.Run(context.Background())
+	reached := clock.Run(duration)
+
+	r := stats.Report()
+	c := comparison{
+		Algorithm:    impl.name,
+		Reached:      reached,
+		Meals:        r.Meals,
+		MealsPerHour: float64(r.Meals) / duration.Hours(),
+		MeanWait:     r.Wait.Avg,
+		P99Wait:      r.Wait.P99,
+		Fairness:     r.MealFairness,
+		MaxEating:    r.MaxEating,
+		Violations:   len(t.Violations()),
+	}
+	messages := 0
+	for mt, count := range t.MessageCounts() {
+		if mt != "shared.NewState" {
+			messages += count
+		}
+	}
+	if r.Meals > 0 {
+		c.MessagesPerMeal = float64(messages) / float64(r.Meals)
+	}
+	if safety != nil {
+		_, c.Unsafe = safety.Found()
+	}
+	return c, nil
+}
+
+// writeComparisons prints the comparisons to out as a table
+func writeComparisons(out *os.File, cs []comparison, duration time.Duration) {
+	var b strings.Builder
+	header := "%-18s %10s %10s %10s %9s %10s %11s  %s\n"
+	row := "%-18s %10.1f %10v %10v %9.4f %10.2f %11d  %s\n"
+	fmt.Fprintf(&b, header, "Algorithm", "Meals/hour", "Wait mean", "p99", "Fairness", "Msgs/meal",
+		"Most eating", "Notes")
+	for _, c := range cs {
+		notes := []string{}
+		if c.Reached < duration {
+			notes = append(notes, fmt.Sprintf("stalled at %v", c.Reached))
+		}
+		if c.Unsafe {
+			notes = append(notes, "unsafe")
+		}
+		if c.Violations > 0 {
+			notes = append(notes, fmt.Sprintf("%d violations", c.Violations))
+		}
+		line := fmt.Sprintf(row, c.Algorithm, c.MealsPerHour, roundDuration(c.MeanWait), roundDuration(c.P99Wait),
+			c.Fairness, c.MessagesPerMeal, c.MaxEating, strings.Join(notes, ", "))
+		b.WriteString(strings.TrimRight(line, " \n") + "\n")
+	}
+	writeString(out, b.String())
+}
+
+// Round a duration for display
+func roundDuration(d time.Duration) time.Duration {
+	return d.Round(10 * time.Millisecond)
+}
+
+// compareMain runs algorithms side by side, given the arguments after the compare subcommand
+func compareMain(args []string) {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	nPhils := flags.Int("n", defaultNPhils(), "number of philosophers at each table - "+nPhilsVar+
+		" in the environment sets the default")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "seed for the random think and eat times (default: taken from "+
+		"the clock)")
+	duration := flags.Duration("duration", 24*time.Hour, "how much virtual time to simulate")
+	pickupDelay := flags.Duration("pickup-delay", 0,
+		"how long naive philosophers wait between picking up their left and right forks")
+	asJSON := flags.Bool("json", false, "print the comparison as JSON")
+	flags.Usage = func() {
+		writeString(os.Stderr, fmt.Sprintf("usage: %s compare [flags] [<implementation> ...]\n", os.Args[0]))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	// Every implementation, unless some are named
+	impls := implementations
+	if flags.NArg() > 0 {
+		impls = nil
+		for _, name := range flags.Args() {
+			found := false
+			for _, i := range implementations {
+				if name == i.name || name == i.short {
+					impls, found = append(impls, i), true
+				}
+			}
+			if !found {
+				writeString(os.Stderr, "unknown implementation: "+name)
+				os.Exit(2)
+			}
+		}
+	}
+
+	if !*asJSON {
+		writeString(os.Stdout, fmt.Sprintf("%d philosophers, seed %d, %v of virtual time\n", *nPhils, *seed, *duration))
+	}
+	cs := []comparison{}
+	for _, i := range impls {
+		c, err := compare(i, *nPhils, *seed, *duration, *pickupDelay)
+		if err != nil {
+			writeString(os.Stderr, err.Error())
+			os.Exit(3)
+		}
+		cs = append(cs, c)
+	}
+
+	if *asJSON {
+		b, err := json.MarshalIndent(cs, "", "  ")
+		if err != nil {
+			os.Exit(4)
+		}
+		writeString(os.Stdout, string(b)+"\n")
+		return
+	}
+	writeComparisons(os.Stdout, cs, *duration)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// compareAll compares the named implementations with the same settings
+func compareAll(t *testing.T, names ...string) []comparison {
+	cs := []comparison{}
+	for _, name := range names {
+		for _, i := range implementations {
+			if i.name == name {
+				c, err := compare(i, 5, 1, time.Hour, 5*time.Second)
+				assert.NoError(t, err, name)
+				cs = append(cs, c)
+			}
+		}
+	}
+	assert.Len(t, cs, len(names))
+	return cs
+}
+
+func TestCompare(t *testing.T) {
+	cs := compareAll(t, "fingers", "chandymisra", "naive")
+	assert.Equal(t, cs, compareAll(t, "fingers", "chandymisra", "naive"), "the same seed should give the same results")
+
+	// Fingers eat whenever they like, and their only messages are their own state changes
+	fingers := cs[0]
+	assert.Equal(t, time.Hour, fingers.Reached)
+	assert.True(t, fingers.Unsafe)
+	assert.Equal(t, 0.0, fingers.MessagesPerMeal)
+	assert.Greater(t, fingers.MaxEating, 2)
+
+	// Chandy-Misra is safe, and each meal costs at most a request and a fork from each neighbor
+	cm := cs[1]
+	assert.Equal(t, time.Hour, cm.Reached)
+	assert.False(t, cm.Unsafe)
+	assert.Positive(t, cm.Meals)
+	assert.Greater(t, cm.MessagesPerMeal, 0.0)
+	assert.LessOrEqual(t, cm.MessagesPerMeal, 4.0)
+	assert.LessOrEqual(t, cm.MaxEating, 2)
+	assert.Zero(t, cm.Violations)
+
+	// Naive philosophers slow to pick up their second fork soon deadlock
+	naive := cs[2]
+	assert.Less(t, naive.Reached, time.Hour)
+	assert.Zero(t, naive.Meals)
+
+	path := filepath.Join(t.TempDir(), "compare.txt")
+	out, err := os.Create(path)
+	assert.NoError(t, err)
+	writeComparisons(out, cs, time.Hour)
+	assert.NoError(t, out.Close())
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if assert.Len(t, lines, 4) {
+		assert.True(t, strings.HasSuffix(lines[1], "  unsafe"), lines[1])
+		assert.NotContains(t, lines[2], "unsafe")
+		assert.NotContains(t, lines[2], "stalled")
+		assert.Contains(t, lines[3], "stalled at "+naive.Reached.String())
+	}
+}
@@ -0,0 +1,243 @@
+package console
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/wizardpb/diningphils-go/monitor"
+	"github.com/wizardpb/diningphils-go/shared"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrQuit is returned by Execute when the user asks to quit
+var ErrQuit = errors.New("quit")
+
+// Clock is a Clock that can be paused, stepped and sped up - a RealClock
+type Clock interface {
+	shared.Clock
+	Pause()
+	Resume()
+	Paused() bool
+	Step() bool
+	SetSpeed(factor float64)
+}
+
+// The help text
+var help = []string{
+	"pause                    freeze the table - the clock stops, and messages are held",
+	"resume                   carry on",
+	"step                     when paused, execute the next message - or move the clock on if there are none",
+	"speed <factor>           run the clock at factor times real time",
+	"think <id> <min> <max>   set how many seconds philosopher id thinks for",
+	"eat <id> <min> <max>     set how many seconds philosopher id eats for",
+	"hungry <id>              make philosopher id hungry now, if it is thinking",
+	"stats                    show the statistics so far",
+	"graph <file>             write the Chandy-Misra precedence graph to file, in Graphviz DOT format",
+	"help                     show this help",
+	"q, quit                  quit",
+}
+
+// Console carries out commands typed at the prompt, to steer a running Table
+type Console struct {
+	Table *shared.Table
+	Stats *shared.Stats
+	// Precedence follows the precedence graph, for the graph command. It is only set for Chandy-Misra
+	Precedence *monitor.PrecedenceGraph
+	// Show displays the output of a command, such as the statistics or the help text
+	Show func(lines []string)
+	// Notify displays a one line notice, such as how far a step has moved the clock
+	Notify func(s string)
+}
+
+// Execute parses and carries out a command line. It returns an error, suitable for showing to the user, if the command
+// is not valid, or ErrQuit if it asks to quit
+func (c *Console) Execute(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	cmd, args := fields[0], fields[1:]
+
+	switch cmd {
+	case "q", "Q", "quit":
+		return ErrQuit
+
+	case "pause", "resume", "step":
+		if err := checkArgs(cmd, args, 0); err != nil {
+			return err
+		}
+		clock, err := c.clock()
+		if err != nil {
+			return err
+		}
+		gate := c.Table.Gate
+		switch cmd {
+		case "pause":
+			clock.Pause()
+			gate.Close()
+			c.notify("paused - step executes one message at a time")
+		case "resume":
+			gate.Open()
+			clock.Resume()
+			c.notify("")
+		case "step":
+			// Messages already sent come first. Only when there are none does the clock move on - which usually sends
+			// one, ready for the next step
+			if !gate.Closed() {
+				return errors.New("step: pause first")
+			}
+			if gate.Step() {
+				break
+			}
+			if !clock.Step() {
+				return errors.New("step: nothing is due to happen")
+			}
+			c.notify(fmt.Sprintf("the clock moves on to %v", clock.Now().Round(time.Millisecond)))
+		}
+
+	case "speed":
+		if err := checkArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		factor, err := strconv.ParseFloat(args[0], 64)
+		if err != nil || factor <= 0 {
+			return fmt.Errorf("speed: %q is not a positive number", args[0])
+		}
+		clock, err := c.clock()
+		if err != nil {
+			return err
+		}
+		clock.SetSpeed(factor)
+
+	case "think", "eat":
+		if err := checkArgs(cmd, args, 3); err != nil {
+			return err
+		}
+		id, err := c.philosopher(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		r, err := timeRange(cmd, args[1], args[2])
+		if err != nil {
+			return err
+		}
+		if cmd == "think" {
+			c.send(id, shared.SetThinkRange{Range: r})
+		} else {
+			c.send(id, shared.SetEatRange{Range: r})
+		}
+
+	case "hungry":
+		if err := checkArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		id, err := c.philosopher(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		c.send(id, shared.MakeHungry{Ignored: func(reason string) {
+			c.notify(fmt.Sprintf("hungry: %s (%d) wasn't made hungry - %s", c.Table.Names[id], id, reason))
+		}})
+
+	case "stats":
+		if err := checkArgs(cmd, args, 0); err != nil {
+			return err
+		}
+		var b bytes.Buffer
+		if _, err := c.Stats.Report().WriteTo(&b); err != nil {
+			return err
+		}
+		c.show(strings.Split(strings.TrimRight(b.String(), "\n"), "\n"))
+
+	case "graph":
+		if err := checkArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		if c.Precedence == nil {
+			return errors.New("graph: there is only a precedence graph for chandymisra")
+		}
+		if err := writeGraph(args[0], c.Precedence.Graph()); err != nil {
+			return fmt.Errorf("graph: %v", err)
+		}
+		c.notify("precedence graph written to " + args[0])
+
+	case "help", "?":
+		c.show(help)
+
+	default:
+		return fmt.Errorf("unknown command %q - try help", cmd)
+	}
+	return nil
+}
+
+// The Table's clock, if it can be controlled
+func (c *Console) clock() (Clock, error) {
+	clock, ok := c.Table.Clock.(Clock)
+	if !ok {
+		return nil, errors.New("this clock can't be controlled")
+	}
+	return clock, nil
+}
+
+// Parse a philosopher ID
+func (c *Console) philosopher(cmd string, arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id < 0 || id >= c.Table.NPhils {
+		return 0, fmt.Errorf("%s: no philosopher %q - they are numbered 0 to %d", cmd, arg, c.Table.NPhils-1)
+	}
+	return id, nil
+}
+
+// Send a control message to philosopher id. This is done in the background, since the philosopher may be busy
+// waiting for a fork
+func (c *Console) send(id int, m shared.Control) {
+	go c.Table.Send(c.Table.Philosophers[id], m)
+}
+
+// Show some output, if there is anywhere to show it
+func (c *Console) show(lines []string) {
+	if c.Show != nil {
+		c.Show(lines)
+	}
+}
+
+// Show a notice, if there is anywhere to show it
+func (c *Console) notify(s string) {
+	if c.Notify != nil {
+		c.Notify(s)
+	}
+}
+
+// Write a precedence graph to a DOT file
+func writeGraph(path string, g monitor.Precedence) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := g.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Check a command has the right number of arguments
+func checkArgs(cmd string, args []string, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("%s: expected %d arguments, got %d - try help", cmd, n, len(args))
+	}
+	return nil
+}
+
+// Parse a range of whole seconds
+func timeRange(cmd string, min, max string) (shared.TimeRange, error) {
+	lo, err1 := strconv.Atoi(min)
+	hi, err2 := strconv.Atoi(max)
+	if err1 != nil || err2 != nil || lo < 0 || hi < lo {
+		return shared.TimeRange{}, fmt.Errorf("%s: %q to %q is not a range of seconds", cmd, min, max)
+	}
+	return shared.TimeRange{Min: lo, Max: hi, Unit: time.Second}, nil
+}
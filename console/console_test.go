@@ -0,0 +1,114 @@
+package console_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/console"
+	"github.com/wizardpb/diningphils-go/monitor"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestErrors(t *testing.T) {
+	table, _ := shared.NewTable(3, shared.DiscardOutput{})
+	c := &console.Console{Table: table, Stats: shared.NewStats(table.Names)}
+	for line, msg := range map[string]string{
+		"dance":            `unknown command "dance" - try help`,
+		"pause now":        "pause: expected 0 arguments, got 1 - try help",
+		"step":             "step: pause first",
+		"speed fast":       `speed: "fast" is not a positive number`,
+		"speed -1":         `speed: "-1" is not a positive number`,
+		"think 3 1 2":      `think: no philosopher "3" - they are numbered 0 to 2`,
+		"eat 0 5 1":        `eat: "5" to "1" is not a range of seconds`,
+		"hungry":           "hungry: expected 1 arguments, got 0 - try help",
+		"hungry Aristotle": `hungry: no philosopher "Aristotle" - they are numbered 0 to 2`,
+		"graph g.dot":      "graph: there is only a precedence graph for chandymisra",
+	} {
+		err := c.Execute(line)
+		if assert.Error(t, err, line) {
+			assert.Equal(t, msg, err.Error())
+		}
+	}
+	assert.NoError(t, c.Execute("  "))
+	assert.Equal(t, console.ErrQuit, c.Execute("q"))
+}
+
+func TestCommands(t *testing.T) {
+	// Everyone thinks for an hour, then eats for an hour - unless told otherwise
+	table, _ := shared.NewTable(2, shared.DiscardOutput{})
+	table.ThinkRange = shared.TimeRange{Min: 3600, Max: 3600, Unit: time.Second}
+	table.EatRange = shared.TimeRange{Min: 3600, Max: 3600, Unit: time.Second}
+	stats := shared.NewStats(table.Names)
+	table.Sinks = append(table.Sinks, stats)
+	shown := []string{}
+	notices := make(chan string, 10)
+	c := &console.Console{Table: table, Stats: stats, Show: func(lines []string) { shown = lines },
+		Notify: func(s string) { notices <- s }}
+	table.Seat(chandymisra.Factory)
+	ctx, stop := context.WithCancel(context.Background())
+	table.Run(ctx)
+	state := func(id int) philstate.Enum { return table.Philosophers[id].GetState() }
+
+	// Philosopher 1 is made hungry, and eats straight away. Philosopher 0 then has to wait for the forks, so it can't
+	// be made hungry again
+	assert.NoError(t, c.Execute("hungry 1"))
+	assert.Eventually(t, func() bool { return state(1) == philstate.Eating }, 5*time.Second, time.Millisecond)
+	assert.NoError(t, c.Execute("hungry 0"))
+	assert.Eventually(t, func() bool { return state(0) == philstate.Hungry }, 5*time.Second, time.Millisecond)
+	assert.NoError(t, c.Execute("hungry 0"))
+	select {
+	case notice := <-notices:
+		assert.Equal(t, "hungry: Hannah Arendt (0) wasn't made hungry - it is Hungry, not Thinking", notice)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "no notice that philosopher 0 wasn't made hungry")
+	}
+	assert.Equal(t, 1, stats.Report().Meals)
+
+	assert.NoError(t, c.Execute("think 0 0 1"))
+	assert.NoError(t, c.Execute("eat 0 0 1"))
+	assert.NoError(t, c.Execute("pause"))
+	assert.NoError(t, c.Execute("step"))
+	assert.NoError(t, c.Execute("speed 2"))
+	assert.NoError(t, c.Execute("resume"))
+
+	assert.NoError(t, c.Execute("stats"))
+	assert.Contains(t, shown[0], "Philosopher")
+	assert.NoError(t, c.Execute("help"))
+	assert.Contains(t, shown[0], "pause")
+
+	// Speed up the clock, so that nobody takes an hour to finish eating
+	assert.NoError(t, c.Execute("speed 100000"))
+	stop()
+	wait, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, table.Wait(wait))
+}
+
+func TestGraph(t *testing.T) {
+	table, _ := shared.NewTable(2, shared.DiscardOutput{})
+	table.ThinkRange = shared.TimeRange{Min: 1, Max: 5, Unit: time.Millisecond}
+	table.EatRange = shared.TimeRange{Min: 1, Max: 5, Unit: time.Millisecond}
+	graph := monitor.NewPrecedenceGraph(table)
+	table.Sinks = append(table.Sinks, graph)
+	c := &console.Console{Table: table, Stats: shared.NewStats(table.Names), Precedence: graph}
+	table.Seat(chandymisra.Factory)
+	ctx, stop := context.WithCancel(context.Background())
+	table.Run(ctx)
+
+	path := filepath.Join(t.TempDir(), "graph.dot")
+	assert.NoError(t, c.Execute("graph "+path))
+	dot, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(dot), "digraph precedence")
+	assert.Error(t, c.Execute("graph "+filepath.Join(path, "nowhere.dot")))
+
+	stop()
+	wait, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, table.Wait(wait))
+}
@@ -0,0 +1,9 @@
+package drinking
+
+import "github.com/wizardpb/diningphils-go/shared"
+
+// Bottle is shared by the two philosophers at the ends of a conflict graph edge. Like a fork, it is held by at most
+// one of them at a time, but unlike a fork it is never dirty - precedence comes from the fork on the same edge.
+type Bottle struct {
+	shared.ForkBase
+}
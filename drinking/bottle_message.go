@@ -0,0 +1,17 @@
+package drinking
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared"
+)
+
+// BottleMessage sends a Bottle to a Philosopher
+type BottleMessage struct {
+	Sender shared.Philosopher
+	Bottle *Bottle
+}
+
+// String implements the Stringer interface
+func (m BottleMessage) String() string {
+	return fmt.Sprintf("Philosopher %d sends bottle %d", m.Sender.GetID(), m.Bottle.ID)
+}
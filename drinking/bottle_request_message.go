@@ -0,0 +1,17 @@
+package drinking
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared"
+)
+
+// BottleRequestMessage requests a Bottle from a Philosopher. Reception causes the bottle request flag to be set
+type BottleRequestMessage struct {
+	Requester shared.Philosopher
+	Bottle    *Bottle
+}
+
+// String implements the Stringer interface
+func (m BottleRequestMessage) String() string {
+	return fmt.Sprintf("Philosopher %d requests bottle %d", m.Requester.GetID(), m.Bottle.ID)
+}
@@ -0,0 +1,69 @@
+package drinking
+
+import "github.com/wizardpb/diningphils-go/shared"
+
+// Edge is an edge of the conflict graph. The two philosophers it joins share one fork and one bottle.
+type Edge struct {
+	ID   int
+	U, V int
+}
+
+// Graph is an undirected conflict graph over the philosophers
+type Graph []Edge
+
+// NewGraph creates a conflict graph for n philosophers: the usual ring around the table, plus a random selection of
+// chords, chosen using rnd, between philosophers that are not seated next to each other.
+//
+// Ring edges are numbered so that edge i joins philosopher i to the philosopher on its left, just like fork i in the
+// dining implementations. Chords are numbered from n upwards.
+func NewGraph(n int, rnd shared.Rand) Graph {
+	g := Graph{}
+	for i := 0; i < n; i++ {
+		g = append(g, Edge{ID: i, U: (i + n - 1) % n, V: i})
+	}
+	for u := 0; u < n; u++ {
+		for v := u + 2; v < n; v++ {
+			if u == 0 && v == n-1 {
+				// Already joined by the ring
+				continue
+			}
+			if shared.RandBool(rnd) {
+				g = append(g, Edge{ID: len(g), U: u, V: v})
+			}
+		}
+	}
+	return g
+}
+
+// Neighbors returns the philosophers that share an edge with philosopher id, each only once - with two philosophers
+// at the table the ring joins them twice
+func (g Graph) Neighbors(id int) []int {
+	neighbors := []int{}
+	seen := map[int]bool{}
+	for _, e := range g {
+		n := e.U
+		switch id {
+		case e.U:
+			n = e.V
+		case e.V:
+		default:
+			continue
+		}
+		if !seen[n] {
+			seen[n] = true
+			neighbors = append(neighbors, n)
+		}
+	}
+	return neighbors
+}
+
+// Degree returns the number of edges touching philosopher id
+func (g Graph) Degree(id int) int {
+	d := 0
+	for _, e := range g {
+		if e.U == id || e.V == id {
+			d++
+		}
+	}
+	return d
+}
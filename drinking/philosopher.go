@@ -0,0 +1,283 @@
+package drinking
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+)
+
+// bottleLink is the bottle on one of a Philosopher's links, shared with the neighbor on the same edge of the conflict
+// graph, and this end's request token for it
+type bottleLink struct {
+	bottle  *Bottle
+	request bool
+	needed  bool // the bottle is needed for the current drinking session
+}
+
+// Philosopher implementation - a chandymisra.Diner over the edges of the conflict graph, with a bottle on each edge.
+//
+// The drinking state is kept in State, so that it shows on the screen like any other implementation: Thinking means
+// tranquil, Hungry means thirsty and Eating means drinking. The underlying diners state, used only to resolve
+// conflicts over bottles, is kept separately in dining.
+type Philosopher struct {
+	chandymisra.Diner
+	dining  philstate.Enum
+	bottles []*bottleLink // bottles[i] is on the same edge as Links[i]
+}
+
+// setting is the drinking state shared by everyone at a Table: the conflict graph, and the fork and bottle on each of
+// its edges.
+//
+// Each fork and bottle starts with the lower numbered philosopher on its edge, and the forks are dirty, so the
+// higher numbered philosopher always has precedence. Precedence therefore follows philosopher number, and the
+// precedence graph is acyclic.
+type setting struct {
+	graph   Graph
+	forks   []*chandymisra.Fork // forks[i] is on edge i
+	bottles []*Bottle           // and so is bottles[i]
+}
+
+// The key for the Table value holding the table's setting
+type settingKey struct{}
+
+// tableSetting returns the setting for Table t, choosing its conflict graph the first time it is asked for
+func tableSetting(t *shared.Table) *setting {
+	return t.Value(settingKey{}, func() interface{} {
+		s := &setting{graph: NewGraph(t.NPhils, t.Rand)}
+		for _, e := range s.graph {
+			f := &chandymisra.Fork{ForkBase: &shared.ForkBase{ID: e.ID}, Dirty: true}
+			b := &Bottle{ForkBase: shared.ForkBase{ID: e.ID}}
+			lo := e.U
+			if e.V < lo {
+				lo = e.V
+			}
+			f.SetHolder(lo)
+			b.SetHolder(lo)
+			s.forks = append(s.forks, f)
+			s.bottles = append(s.bottles, b)
+		}
+		return s
+	}).(*setting)
+}
+
+// TableGraph returns the conflict graph of the philosophers seated at Table t. It is chosen when they are seated
+func TableGraph(t *shared.Table) Graph {
+	return tableSetting(t).graph
+}
+
+// Find the bottle link carrying bottle b
+func (p *Philosopher) linkForBottle(b *Bottle) *bottleLink {
+	for _, bl := range p.bottles {
+		if bl.bottle == b {
+			return bl
+		}
+	}
+	panic(fmt.Sprintf("bottle %d is not shared with philosopher %d", b.ID, p.ID))
+}
+
+// Do I hold every bottle I need for this session?
+func (p *Philosopher) holdsNeededBottles() bool {
+	for _, bl := range p.bottles {
+		if bl.needed && !bl.bottle.IsHeldBy(p.ID) {
+			return false
+		}
+	}
+	return true
+}
+
+// Should I hang on to the bottle bl, even though it has been requested? Only if I need it and I am either drinking
+// from it, or thirsty and holding the fork on the same edge, l - which gives me precedence over the neighbor
+func (p *Philosopher) keepsBottle(l *chandymisra.Link, bl *bottleLink) bool {
+	return bl.needed && (p.IsEating() || (p.IsHungry() && l.Fork.IsHeldBy(p.ID)))
+}
+
+// Choose a random, non-empty, subset of my bottles for the next session
+func (p *Philosopher) chooseBottles() {
+	chosen := false
+	for !chosen {
+		for _, bl := range p.bottles {
+			bl.needed = shared.RandBool(p.Rand)
+			chosen = chosen || bl.needed
+		}
+	}
+}
+
+// IDs of the bottles I need, or hold
+func (p *Philosopher) bottleIDs(needed bool) []int {
+	ids := []int{}
+	for _, bl := range p.bottles {
+		if (needed && bl.needed) || (!needed && bl.bottle.IsHeldBy(p.ID)) {
+			ids = append(ids, bl.bottle.ID)
+		}
+	}
+	return ids
+}
+
+// report writes a string to the screen, adding the bottles held
+func (p *Philosopher) report(s string) {
+	p.WriteString(fmt.Sprintf("%s, has bottles %v", s, p.bottleIDs(false)))
+}
+
+// Eat is the diners layer eating action - it dirties all forks. Eating is only a means of gaining precedence over
+// the neighbors, so it takes no time: the Philosopher stops as soon as it is no longer thirsty
+func (p *Philosopher) eat() {
+	p.DirtyForks()
+	p.dining = philstate.Eating
+	p.report("holds all forks and has precedence")
+}
+
+// drink starts a drinking session, checking that we hold all the bottles we need
+func (p *Philosopher) drink() {
+	p.Check(p.holdsNeededBottles(), nil, "drinking without holding all needed bottles")
+	p.SetState(philstate.Eating)
+	p.report(fmt.Sprintf("starts drinking from bottles %v", p.bottleIDs(true)))
+	p.DelaySend(p.EatRange, shared.NewState{NewState: philstate.Thinking})
+}
+
+// Execute extends the hygienic fork scheme of chandymisra.Philosopher.Execute with bottles, following the drinking
+// philosophers solution in https://www.cs.utexas.edu/users/misra/scannedPdf.dir/DrinkingPhil.pdf
+//
+// The forks, request tokens and rules R1-R4 are those of the diners solution, carried out by chandymisra.Diner over
+// every edge of the conflict graph. A thirsty philosopher is hungry in the diners layer, and a philosopher holding a
+// fork has precedence for the bottle on the same edge. A philosopher that eats therefore has precedence over all of its
+// neighbors, and will get all the bottles it needs.
+func (p *Philosopher) Execute(m shared.Message) {
+	// Update any state change indicated by the message...
+	switch mt := m.(type) {
+
+	case shared.NewState:
+		// Update our state value
+		p.SetState(mt.NewState)
+		switch p.State {
+		case philstate.Hungry:
+			p.chooseBottles()
+			p.report(fmt.Sprintf("is thirsty for bottles %v", p.bottleIDs(true)))
+		case philstate.Thinking:
+			for _, bl := range p.bottles {
+				bl.needed = false
+			}
+			// The session is over, so the diners layer is done too - even if it never got to eat. Left hungry, it
+			// would keep asking for forks it has no use for
+			p.dining = philstate.Thinking
+			p.report("is tranquil")
+			p.ScheduleHunger()
+		}
+
+	case chandymisra.ForkMessage:
+		p.ReceiveFork(mt)
+
+	case chandymisra.ForkRequestMessage:
+		p.ReceiveRequest(mt)
+
+	case BottleMessage:
+		// Receive a bottle
+		bl := p.linkForBottle(mt.Bottle)
+		p.Check(!bl.bottle.IsHeld(), nil, "bottle %d already held by %d", bl.bottle.ID, bl.bottle.Holder())
+		bl.bottle.SetHolder(p.ID)
+		p.EmitBottle(shared.BottleReceived, mt.Sender.GetID(), bl.bottle.ID)
+		p.report(fmt.Sprintf("receives bottle %d", bl.bottle.ID))
+
+	case BottleRequestMessage:
+		// Receive a bottle request
+		bl := p.linkForBottle(mt.Bottle)
+		p.Check(!bl.request, nil, "bottle %d has already been requested", bl.bottle.ID)
+		bl.request = true
+		p.EmitBottle(shared.BottleRequestReceived, mt.Requester.GetID(), bl.bottle.ID)
+
+	default:
+		p.WriteString("unknown message: " + m.String())
+	}
+
+	// ... then make any state changes now enabled. A thirsty philosopher is hungry, and a hungry philosopher with all
+	// its forks eats
+	if p.IsHungry() && p.dining == philstate.Thinking {
+		p.dining = philstate.Hungry
+	}
+	if p.dining == philstate.Hungry && p.HoldsAllForks() {
+		p.eat()
+	}
+	// A thirsty philosopher with all the bottles it needs drinks...
+	if p.IsHungry() && p.holdsNeededBottles() {
+		p.drink()
+	}
+	// ... and an eating philosopher that is no longer thirsty stops eating
+	if p.dining == philstate.Eating && !p.IsHungry() {
+		p.dining = philstate.Thinking
+	}
+
+	// ... and finally check for any implied message send: the forks, by the rules of the diners layer...
+	p.SendForks(p.dining)
+
+	// ... and the bottles, on each edge
+	for i, bl := range p.bottles {
+		l := p.Links[i]
+		neighbor := p.Table.Philosophers[l.Neighbor]
+		switch {
+
+		case p.IsHungry() && bl.needed && bl.request && !bl.bottle.IsHeldBy(p.ID):
+			// I'm thirsty and need a bottle I don't have - request it
+			bl.request = false
+			p.EmitBottle(shared.BottleRequested, l.Neighbor, bl.bottle.ID)
+			p.Send(neighbor, BottleRequestMessage{Requester: p, Bottle: bl.bottle})
+			p.report(fmt.Sprintf("requested bottle %d", bl.bottle.ID))
+
+		case bl.request && bl.bottle.IsHeldBy(p.ID) && !p.keepsBottle(l, bl):
+			// The bottle has been requested, and I have no claim on it - send it over
+			bl.bottle.SetFree()
+			p.EmitBottle(shared.BottleSent, l.Neighbor, bl.bottle.ID)
+			p.Send(neighbor, BottleMessage{Sender: p, Bottle: bl.bottle})
+			p.report(fmt.Sprintf("sent bottle %d to philosopher %d", bl.bottle.ID, l.Neighbor))
+		}
+	}
+}
+
+// Factory is the creation function for a Philosopher. It links the Philosopher to every edge of the conflict graph it
+// is on, with the request tokens starting opposite the forks and bottles. The Fork returned is the ring fork to its
+// left - forks on chords of the conflict graph belong to no one Philosopher.
+//
+// Doing this here, rather than in Start, means nobody touches another philosopher's links - every philosopher starts
+// in its own goroutine, and a neighbor may already be running.
+func Factory(t *shared.Table, params shared.CreateParams) (shared.Philosopher, shared.Fork) {
+	setting := tableSetting(t)
+
+	p := &Philosopher{
+		Diner: chandymisra.Diner{
+			PhilosopherBase: &shared.PhilosopherBase{
+				Table:      t,
+				ID:         params.ID,
+				Name:       params.Name,
+				State:      philstate.Inactive,
+				ThinkRange: params.ThinkRange,
+				EatRange:   params.EatRange,
+				Rand:       params.Rand,
+				// Each request token, fork and bottle is unique, so there can be at most four messages per edge in
+				// flight to us, plus a state change. A channel that size means senders never block
+				MessageChan: make(chan shared.Message, 4*setting.graph.Degree(params.ID)+1),
+			},
+		},
+		dining: philstate.Thinking,
+	}
+	p.Self = p
+	for _, e := range setting.graph {
+		neighbor := e.U
+		switch params.ID {
+		case e.U:
+			neighbor = e.V
+		case e.V:
+		default:
+			continue
+		}
+		f, b := setting.forks[e.ID], setting.bottles[e.ID]
+		p.Links = append(p.Links, &chandymisra.Link{Neighbor: neighbor, Fork: f, Request: !f.IsHeldBy(p.ID)})
+		p.bottles = append(p.bottles, &bottleLink{bottle: b, request: !b.IsHeldBy(p.ID)})
+	}
+	return p, setting.forks[params.ID]
+}
+
+// Start implements the Philosopher interface
+func (p *Philosopher) Start() {
+	p.SetState(philstate.Thinking)
+	p.report("is tranquil")
+	p.ScheduleHunger()
+}
@@ -0,0 +1,65 @@
+package drinking_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/drinking"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventCounter counts the events of each type, and the fork requests sent by tranquil philosophers
+type eventCounter struct {
+	lock             sync.Mutex
+	counts           map[shared.EventType]int
+	tranquilRequests int
+}
+
+func (c *eventCounter) Record(e shared.Event) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.counts[e.Type]++
+	if e.Type == shared.ForkRequested && e.State == philstate.Thinking {
+		c.tranquilRequests++
+	}
+}
+
+// Run a table of drinking philosophers for an hour of virtual time, counting its events
+func run(t *testing.T) (*shared.Table, *eventCounter) {
+	table, _ := shared.NewTable(6, shared.DiscardOutput{})
+	clock := shared.NewVirtualClock()
+	counter := &eventCounter{counts: map[shared.EventType]int{}}
+	table.Clock = clock
+	table.Seed = 1
+	table.Sinks = append(table.Sinks, counter)
+	table.Seat(drinking.Factory)
+	table.Run(context.Background())
+	assert.Equal(t, time.Hour, clock.Run(time.Hour), "the table stalled")
+	assert.Empty(t, table.Violations())
+	return table, counter
+}
+
+func TestTranquilRequestsNoForks(t *testing.T) {
+	_, counter := run(t)
+
+	// Forks are only needed to settle who gets a bottle, so only a thirsty philosopher asks for them
+	assert.Greater(t, counter.counts[shared.ForkRequested], 100)
+	assert.Zero(t, counter.tranquilRequests, "fork requests sent by tranquil philosophers")
+}
+
+func TestBottleEvents(t *testing.T) {
+	table, counter := run(t)
+
+	// Every bottle and request received was sent, and only the last few sent can still be on their way
+	counts := counter.counts
+	edges := len(drinking.TableGraph(table))
+	assert.Greater(t, counts[shared.BottleReceived], 100)
+	assert.LessOrEqual(t, counts[shared.BottleReceived], counts[shared.BottleSent])
+	assert.LessOrEqual(t, counts[shared.BottleSent]-counts[shared.BottleReceived], edges)
+	assert.Greater(t, counts[shared.BottleRequestReceived], 100)
+	assert.LessOrEqual(t, counts[shared.BottleRequestReceived], counts[shared.BottleRequested])
+	assert.LessOrEqual(t, counts[shared.BottleRequested]-counts[shared.BottleRequestReceived], edges)
+}
@@ -16,7 +16,7 @@ func (p *Philosopher) Execute(m shared.Message) {
 	switch mt := m.(type) {
 	case shared.NewState:
 		// Update our state value
-		p.State = mt.NewState
+		p.SetState(mt.NewState)
 		switch p.State {
 		case philstate.Hungry:
 			// When eating with fingers - no need to wait for forks!
@@ -30,16 +30,17 @@ func (p *Philosopher) Execute(m shared.Message) {
 }
 
 // Factory is the Philosopher and Fork creation function
-func Factory(params shared.CreateParams) (shared.Philosopher, shared.Fork) {
+func Factory(t *shared.Table, params shared.CreateParams) (shared.Philosopher, shared.Fork) {
 	return &Philosopher{&shared.PhilosopherBase{
+			Table:       t,
 			ID:          params.ID,
 			Name:        params.Name,
 			State:       philstate.Inactive,
 			ThinkRange:  params.ThinkRange,
 			EatRange:    params.EatRange,
+			Rand:        params.Rand,
 			MessageChan: make(chan shared.Message, 0),
 		}}, &shared.ForkBase{
-			ID:     params.ID,
-			Holder: shared.UnOwned,
+			ID: params.ID,
 		}
 }
@@ -0,0 +1,71 @@
+package footman
+
+import (
+	"github.com/wizardpb/diningphils-go/semfork"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+)
+
+// Philosopher implementation
+type Philosopher struct {
+	semfork.Philosopher
+	seats *shared.Semaphore
+}
+
+// Execute implements the Philosopher interface for the Footman implementation. Wait for a seat when hungry, then
+// collect the forks left first. Put them back and leave the table when done.
+func (p *Philosopher) Execute(m shared.Message) {
+	switch mt := m.(type) {
+	case shared.NewState:
+		// Update our state value
+		p.SetState(mt.NewState)
+		switch p.State {
+		case philstate.Hungry:
+			p.sitDown()
+			p.PickUp(p.LeftFork())
+			p.PickUp(p.RightFork())
+			p.Eat()
+		case philstate.Thinking:
+			p.PutDown(p.RightFork())
+			p.PutDown(p.LeftFork())
+			p.standUp()
+			p.StartThinking()
+		}
+	default:
+		panic("unknown message: " + m.String())
+	}
+}
+
+// Wait for the footman to show us to a free seat
+func (p *Philosopher) sitDown() {
+	p.seats.Acquire()
+	p.WriteString("sits down at the table")
+}
+
+// Give our seat back to the footman
+func (p *Philosopher) standUp() {
+	p.seats.Release()
+	p.WriteString("leaves the table")
+}
+
+// Factory function for Philosopher and Fork
+func Factory(t *shared.Table, params shared.CreateParams) (shared.Philosopher, shared.Fork) {
+
+	p := &Philosopher{
+		Philosopher: semfork.Philosopher{
+			PhilosopherBase: &shared.PhilosopherBase{
+				Table:       t,
+				ID:          params.ID,
+				Name:        params.Name,
+				State:       philstate.Inactive,
+				ThinkRange:  params.ThinkRange,
+				EatRange:    params.EatRange,
+				Rand:        params.Rand,
+				MessageChan: make(chan shared.Message, 0),
+			},
+		},
+		seats: tableSeats(t),
+	}
+
+	return p, semfork.NewFork(t, params.ID)
+}
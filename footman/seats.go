@@ -0,0 +1,20 @@
+package footman
+
+import "github.com/wizardpb/diningphils-go/shared"
+
+// The key for the Table value holding the table's seats
+type seatsKey struct{}
+
+// tableSeats returns the seats at Table t, creating them the first time they are asked for.
+//
+// The seats are a counting semaphore controlling access to the table. It holds one unit for every free seat, and
+// there are only NPhils-1 of them, so at least one Philosopher is always away from the table. A Philosopher takes a
+// seat before reaching for any fork, and gives it back after putting both forks down.
+//
+// With at most NPhils-1 Philosophers competing for NPhils forks, at least one of them can always get both forks, so
+// the left-then-right pickup order can never deadlock.
+func tableSeats(t *shared.Table) *shared.Semaphore {
+	return t.Value(seatsKey{}, func() interface{} {
+		return shared.NewSemaphore(t.Clock, t.NPhils-1)
+	}).(*shared.Semaphore)
+}
@@ -0,0 +1,91 @@
+package lehmannrabin
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/semfork"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"time"
+)
+
+// How long a Philosopher waits before trying again after giving up a fork. This just stops a Philosopher spinning
+// while a neighbor eats - it plays no part in the correctness of the algorithm
+var retryRange = shared.TimeRange{Min: 100, Max: 500, Unit: time.Millisecond}
+
+// Philosopher implementation
+type Philosopher struct {
+	semfork.Philosopher
+	// Flip tosses a fair coin: true means try the left fork first. It is the source of randomness for the algorithm
+	Flip func() bool
+	// The number of times we have had to put a fork down while hungry
+	retries int
+}
+
+// Execute implements the Philosopher interface for the Lehmann-Rabin implementation. When hungry, choose a fork at
+// random, wait for it, and then take the other if it is free. If it isn't, put the first fork back and choose again
+func (p *Philosopher) Execute(m shared.Message) {
+	switch mt := m.(type) {
+	case shared.NewState:
+		// Update our state value
+		p.SetState(mt.NewState)
+		switch p.State {
+		case philstate.Hungry:
+			p.retries = 0
+			p.tryToEat()
+		case philstate.Thinking:
+			p.PutDown(p.LeftFork())
+			p.PutDown(p.RightFork())
+			p.StartThinking()
+		}
+	case RetryMessage:
+		if p.IsHungry() {
+			p.tryToEat()
+		}
+	default:
+		panic("unknown message: " + m.String())
+	}
+}
+
+// tryToEat makes one attempt at the Lehmann-Rabin 'free choice' protocol
+func (p *Philosopher) tryToEat() {
+	first, second := p.LeftFork(), p.RightFork()
+	if !p.Flip() {
+		first, second = second, first
+	}
+
+	p.WriteString(fmt.Sprintf("flips a coin and waits for fork %d", first.GetID()))
+	p.PickUp(first)
+	if p.TryPickUp(second) {
+		p.Eat()
+		return
+	}
+
+	// The second fork is busy - give up the first one and start over with a new coin toss
+	p.PutDown(first)
+	p.retries++
+	p.WriteString(fmt.Sprintf("finds fork %d busy, puts down fork %d and retries (%d)", second.GetID(), first.GetID(),
+		p.retries))
+	p.DelaySend(retryRange, RetryMessage{})
+}
+
+// Factory function for Philosopher and Fork
+func Factory(t *shared.Table, params shared.CreateParams) (shared.Philosopher, shared.Fork) {
+
+	p := &Philosopher{
+		Philosopher: semfork.Philosopher{
+			PhilosopherBase: &shared.PhilosopherBase{
+				Table:       t,
+				ID:          params.ID,
+				Name:        params.Name,
+				State:       philstate.Inactive,
+				ThinkRange:  params.ThinkRange,
+				EatRange:    params.EatRange,
+				Rand:        params.Rand,
+				MessageChan: make(chan shared.Message, 0),
+			},
+		},
+		Flip: func() bool { return shared.RandBool(params.Rand) },
+	}
+
+	return p, semfork.NewFork(t, params.ID)
+}
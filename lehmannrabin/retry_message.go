@@ -0,0 +1,9 @@
+package lehmannrabin
+
+// RetryMessage is sent by a Philosopher to itself after it had to put a fork back down, to make another attempt
+type RetryMessage struct{}
+
+// String implements the Stringer interface
+func (m RetryMessage) String() string {
+	return "Retry fork pickup"
+}
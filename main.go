@@ -1,32 +1,379 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/console"
+	"github.com/wizardpb/diningphils-go/drinking"
 	"github.com/wizardpb/diningphils-go/fingers"
+	"github.com/wizardpb/diningphils-go/footman"
+	"github.com/wizardpb/diningphils-go/lehmannrabin"
+	"github.com/wizardpb/diningphils-go/monitor"
+	"github.com/wizardpb/diningphils-go/naive"
 	"github.com/wizardpb/diningphils-go/resourcehierarchy"
 	"github.com/wizardpb/diningphils-go/screen"
+	"github.com/wizardpb/diningphils-go/sequence"
 	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/waiter"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
-func Initialize(f shared.Factory) {
-	// Create Forks and Philosophers
-	for i, name := range shared.PhilNames {
-		params := shared.CreateParams{
-			ID:         i,
-			Name:       name,
-			ThinkRange: shared.TimeRange{Min: shared.ThinkMin, Max: shared.ThinkMax, Unit: time.Second},
-			EatRange:   shared.TimeRange{Min: shared.EatMin, Max: shared.EatMax, Unit: time.Second},
+// How fast the clock runs while the table shuts down, and how long to wait for it
+const (
+	shutdownSpeed   = 10
+	shutdownTimeout = 10 * time.Second
+)
+
+// nPhilsVar is the environment variable that sets the number of philosophers, when -n doesn't
+const nPhilsVar = "DININGPHILS_N"
+
+// An implementation that can be run, by its full and short names
+type implementation struct {
+	name, short string
+	// factory returns the Factory. pickupDelay is the time naive philosophers take between picking up their forks
+	factory func(pickupDelay time.Duration) shared.Factory
+}
+
+// Every implementation
+var implementations = []implementation{
+	{"fingers", "f", func(time.Duration) shared.Factory { return fingers.Factory }},
+	{"resourcehierarchy", "rh", func(time.Duration) shared.Factory { return resourcehierarchy.Factory }},
+	{"chandymisra", "cm", func(time.Duration) shared.Factory { return chandymisra.Factory }},
+	{"waiter", "w", func(time.Duration) shared.Factory { return waiter.Factory }},
+	{"footman", "fm", func(time.Duration) shared.Factory { return footman.Factory }},
+	{"lehmannrabin", "lr", func(time.Duration) shared.Factory { return lehmannrabin.Factory }},
+	{"drinking", "dp", func(time.Duration) shared.Factory { return drinking.Factory }},
+	{"naive", "n", naive.NewFactory},
+}
+
+// factoryFor returns the Factory for the named implementation, or nil if there is no such implementation. pickupDelay
+// is the time naive philosophers take between picking up their forks
+func factoryFor(impl string, pickupDelay time.Duration) shared.Factory {
+	for _, i := range implementations {
+		if impl == i.name || impl == i.short {
+			return i.factory(pickupDelay)
+		}
+	}
+	return nil
+}
+
+// implementationName returns the full name of the named implementation, which may be given by its short name
+func implementationName(impl string) string {
+	for _, i := range implementations {
+		if impl == i.short {
+			return i.name
+		}
+	}
+	return impl
+}
+
+// defaultNPhils returns the number of philosophers to seat if -n isn't given: from the environment, if it is set there
+// and not empty, or the usual number
+func defaultNPhils() int {
+	v := os.Getenv(nPhilsVar)
+	if v == "" {
+		return shared.DefaultNPhils
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		writeString(os.Stderr, fmt.Sprintf("%s: %q is not a number\n", nPhilsVar, v))
+		os.Exit(1)
+	}
+	return n
+}
+
+// nPhilsFlag defines the -n flag in fs
+func nPhilsFlag(fs *flag.FlagSet) *int {
+	return fs.Int("n", defaultNPhils(), "number of philosophers at the table - "+nPhilsVar+
+		" in the environment sets the default")
+}
+
+// newSafetyMonitor creates a safety monitor for the named implementation, checking the precedence graph for
+// Chandy-Misra. There is none for the drinking philosophers, who don't sit in a ring
+func newSafetyMonitor(t *shared.Table, impl string) *monitor.SafetyMonitor {
+	switch impl {
+	case "drinking", "dp":
+		return nil
+	}
+	safety := monitor.NewSafetyMonitor(t)
+	safety.Precedence = impl == "chandymisra" || impl == "cm"
+	return safety
+}
+
+// snapshotGraphs writes the precedence graph to a numbered DOT file in dir after every state change. It returns a
+// function that returns the first error writing them, if there was one
+func snapshotGraphs(graph *monitor.PrecedenceGraph, dir string) (failed func() error) {
+	count := 0
+	var first error
+	graph.OnChange = func(g monitor.Precedence) {
+		if first != nil {
+			return
+		}
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("precedence-%05d.dot", count)))
+		if err != nil {
+			first = err
+			return
+		}
+		count++
+		if _, err := g.WriteTo(f); err != nil {
+			first = err
+		}
+		if err := f.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return func() error { return first }
+}
+
+// writeSequence writes the sequence diagram recorded to path
+func writeSequence(recorder *sequence.Recorder, path string, format sequence.Format) {
+	f, err := os.Create(path)
+	if err == nil {
+		err = recorder.WriteDiagram(f, format)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		writeString(os.Stderr, "error writing sequence diagram: "+err.Error()+"\n")
+	}
+}
+
+// runHeadless runs the table without the screen for the given duration - in virtual time, or in real time, shutting it
+// down at the end - and then prints a report, or writes it as JSON. It returns true if the run found anything wrong
+func runHeadless(t *shared.Table, f shared.Factory, impl string, duration time.Duration, virtual, asJSON bool,
+	stats *shared.Stats, detector *monitor.DeadlockDetector, watchdog *monitor.StarvationWatchdog,
+	safety *monitor.SafetyMonitor) (failed bool) {
+	t.Output = shared.DiscardOutput{}
+	var clock *shared.VirtualClock
+	if virtual {
+		clock = shared.NewVirtualClock()
+		t.Clock = clock
+	}
+	if !asJSON {
+		writeString(os.Stdout, fmt.Sprintf("%s: %d philosophers, seed %d\n", impl, t.NPhils, t.Seed))
+	}
+
+	start := time.Now()
+	t.Seat(f)
+	outcome := completed
+	var ran time.Duration
+	var err error
+	if virtual {
+		// There is no need to shut the table down at the end - with nothing driving the clock it is frozen in time
+		t.Run(context.Background())
+		if ran = clock.Run(duration); ran < duration {
+			outcome = stalled
+		}
+	} else {
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		t.Run(ctx)
+		select {
+		case <-time.After(duration):
+		case <-t.Halted():
+		}
+		ran = t.Clock.Now()
+		select {
+		case <-t.Halted():
+		default:
+			if err = shutdown(t, stop); err != nil {
+				outcome = stuck
+			}
+		}
+	}
+	select {
+	case <-t.Halted():
+		outcome = halted
+	default:
+	}
+	r := newRunReport(t, impl, virtual, ran, time.Since(start), outcome, stats, detector, watchdog, safety)
+	if asJSON {
+		r.writeJSON()
+		return r.failed()
+	}
+
+	if virtual {
+		writeString(os.Stdout, fmt.Sprintf("simulated %v in %v\n", r.Duration, r.Elapsed.Round(time.Millisecond)))
+	} else {
+		writeString(os.Stdout, fmt.Sprintf("ran for %v\n", r.Duration.Round(time.Millisecond)))
+	}
+	switch outcome {
+	case halted:
+		writeString(os.Stdout, "the table was halted\n")
+	case stalled:
+		writeString(os.Stdout, "the table stalled - every philosopher is waiting and nothing is scheduled\n")
+	case stuck:
+		writeString(os.Stdout, "the table didn't stop: "+err.Error()+"\n")
+	}
+	writeViolations(t)
+	writeSafety(safety)
+	for _, d := range r.Deadlocks {
+		writeString(os.Stdout, d+"\n")
+	}
+	writeStarvation(t, watchdog)
+	writeMessages(r.Messages)
+	writeReport(stats)
+	return r.failed()
+}
+
+// showStats keeps the live statistics line up to date. It returns a function that stops it
+func showStats(sc *screen.Screen, stats *shared.Stats, line int) (stop func()) {
+	ticker := time.NewTicker(time.Second)
+	quit, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sc.WriteScreenLine(line, 1, stats.Line())
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		<-done
+	}
+}
+
+// shutdown stops the table, and waits until everyone has finished eating and stopped. The table is unpaused, and the
+// clock sped up, so that nobody has to wait long
+func shutdown(t *shared.Table, stop context.CancelFunc) error {
+	t.Gate.Open()
+	if clock, ok := t.Clock.(*shared.RealClock); ok {
+		clock.Resume()
+		clock.SetSpeed(shutdownSpeed)
+	}
+	stop()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return t.Wait(ctx)
+}
+
+// infoArea returns a function that shows lines of command output on screen sc, starting at line, and clearing
+// anything left over from before
+func infoArea(sc *screen.Screen, line int) func(lines []string) {
+	shown := 0
+	return func(lines []string) {
+		for i, l := range lines {
+			sc.WriteScreenLine(line+i, 1, l)
+		}
+		for i := len(lines); i < shown; i++ {
+			sc.WriteScreenLine(line+i, 1, "")
+		}
+		shown = len(lines)
+	}
+}
+
+// readCommands reads command lines in the background. Each prompt sent shows the message given, and the line typed is
+// sent back
+func readCommands(sc *screen.Screen, line int) (prompts chan<- string, commands <-chan string) {
+	p, c := make(chan string), make(chan string)
+	go func() {
+		for message := range p {
+			c <- shared.ReadCmd(sc, line, message)
 		}
-		shared.Philosophers[i], shared.Forks[i] = f(params)
+	}()
+	return p, c
+}
+
+// writeViolations prints the invariant violations found, if there were any, and the state of the table now
+func writeViolations(t *shared.Table) {
+	violations := t.Violations()
+	if len(violations) == 0 {
+		return
+	}
+	for _, v := range violations {
+		writeString(os.Stdout, v.String()+"\n")
+	}
+	writeString(os.Stdout, "table state:\n")
+	if _, err := t.Snapshot().WriteTo(os.Stdout); err != nil {
+		writeFailed.Store(true)
+	}
+}
+
+// writeSafety prints the safety violation found, if there was one, and the events leading up to it
+func writeSafety(safety *monitor.SafetyMonitor) {
+	if safety == nil {
+		return
+	}
+	v, found := safety.Found()
+	if !found {
+		return
+	}
+	writeString(os.Stdout, v.String()+", after:\n")
+	for _, e := range v.Events {
+		writeString(os.Stdout, "    "+e.String()+"\n")
+	}
+}
+
+// writeReport prints the statistics report
+func writeReport(stats *shared.Stats) {
+	if _, err := stats.Report().WriteTo(os.Stdout); err != nil {
+		writeFailed.Store(true)
+	}
+}
+
+// writeStarvation prints how many times each philosopher starved
+func writeStarvation(t *shared.Table, watchdog *monitor.StarvationWatchdog) {
+	bounds := []string{}
+	if watchdog.MaxWait > 0 {
+		bounds = append(bounds, fmt.Sprintf("hungry over %v", watchdog.MaxWait))
+	}
+	if watchdog.MaxNeighborMeals > 0 {
+		bounds = append(bounds, fmt.Sprintf("over %d neighbor meals", watchdog.MaxNeighborMeals))
+	}
+	if len(bounds) == 0 {
+		return
+	}
+	counts := []string{}
+	for i, c := range watchdog.Counts() {
+		counts = append(counts, fmt.Sprintf("%s %d", t.Names[i], c))
+	}
+	writeString(os.Stdout, fmt.Sprintf("starved (%s): %s\n", strings.Join(bounds, " or "), strings.Join(counts, ", ")))
+}
+
+// openTrace creates a trace file at path, and a sink to write events to it, after header h
+func openTrace(path string, h shared.TraceHeader) (*shared.TraceSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return shared.NewTraceSink(f, h), nil
+}
+
+// closeTrace flushes and closes the trace, reporting any error writing it
+func closeTrace(sink *shared.TraceSink) {
+	if err := sink.Close(); err != nil {
+		writeString(os.Stderr, "error writing trace: "+err.Error()+"\n")
+	}
+}
+
+// writeFailed is set when writing to standard output or error fails. Rather than exiting there and then, main carries
+// on - so that the trace, say, is still flushed and closed - and exits with status 4 at the end
+var writeFailed atomic.Bool
+
+// exitIfWriteFailed exits with status 4 if writing to standard output or error failed
+func exitIfWriteFailed() {
+	if writeFailed.Load() {
+		os.Exit(4)
 	}
 }
 
 func writeString(f *os.File, s string) {
 	_, err := f.WriteString(s)
 	if err != nil {
-		os.Exit(4)
+		writeFailed.Store(true)
 	}
 }
 
@@ -37,37 +384,247 @@ func writeString(f *os.File, s string) {
 // https://www.cs.utexas.edu/users/misra/scannedPdf.dir/DrinkingPhil.pdf
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay":
+			replayMain(os.Args[2:])
+			exitIfWriteFailed()
+			return
+		case "compare":
+			compareMain(os.Args[2:])
+			exitIfWriteFailed()
+			return
+		}
+	}
 
-	if len(os.Args) < 2 {
+	nPhils := nPhilsFlag(flag.CommandLine)
+	seed := flag.Int64("seed", 0, "seed for the random think and eat times (default: taken from the clock)")
+	virtual := flag.Bool("virtual", false, "simulate in virtual time, without the screen, and print a summary")
+	headless := flag.Bool("headless", false, "run in real time for -duration without the screen, and print a summary")
+	asJSON := flag.Bool("json", false, "with -virtual or -headless, print the summary as JSON")
+	trace := flag.String("trace", "", "write a JSON Lines trace of the run's events to this file")
+	duration := flag.Duration("duration", 24*time.Hour, "how long to run for with -virtual or -headless")
+	starveWait := flag.Duration("starve-wait", monitor.DefaultMaxWait,
+		"flag philosophers hungry for longer than this (0 to turn off)")
+	starveMeals := flag.Int("starve-meals", 0,
+		"flag philosophers whose neighbors eat more than this many times while they are hungry (0 to turn off)")
+	pickupDelay := flag.Duration("pickup-delay", 0,
+		"how long naive philosophers wait between picking up their left and right forks")
+	graphDir := flag.String("graph-dir", "",
+		"write the chandymisra precedence graph to a numbered DOT file in this directory after every state change")
+	sequencePath := flag.String("sequence", "",
+		"write a sequence diagram of the fork messages, fork requests and state changes delivered to this file")
+	sequenceFormat := flag.String("sequence-format", sequence.PlantUML.String(),
+		"the sequence diagram format: plantuml or mermaid")
+	sequenceFrom := flag.Duration("sequence-from", 0, "start the sequence diagram at this time")
+	sequenceTo := flag.Duration("sequence-to", 0, "end the sequence diagram at this time (default: the end of the run)")
+	onViolation := flag.String("on-violation", shared.HaltOnViolation.String(),
+		"what to do when an invariant is violated: halt (freeze the table and dump it), log, or panic")
+	flag.Usage = func() {
+		writeString(os.Stderr, fmt.Sprintf("usage: %s [flags] <implementation>\n       %s replay [flags] <trace file>\n"+
+			"       %s compare [flags] [<implementation> ...]\n", os.Args[0], os.Args[0], os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
 		writeString(os.Stderr, "missing implementation argument")
 		os.Exit(1)
 	}
 
-	screen.Initialize()
-
-	switch os.Args[1] {
-	case "fingers", "f":
-		Initialize(fingers.Factory)
-	case "resourcehierarchy", "rh":
-		Initialize(resourcehierarchy.Factory)
-	case "chandymisra", "cm":
-		Initialize(chandymisra.Factory)
-	default:
-		writeString(os.Stderr, "unknown implementation: "+os.Args[1])
+	f := factoryFor(flag.Arg(0), *pickupDelay)
+	if f == nil {
+		writeString(os.Stderr, "unknown implementation: "+flag.Arg(0))
 		os.Exit(2)
 	}
 
-	for _, p := range shared.Philosophers {
-		shared.Run(p)
+	// Exit with this status once everything deferred has been done. Flags are all checked before anything - the trace
+	// in particular - needs cleaning up, so up to here it is safe to exit straight away
+	exitStatus := 0
+	defer func() {
+		if exitStatus != 0 {
+			os.Exit(exitStatus)
+		}
+		exitIfWriteFailed()
+	}()
+
+	policy, err := shared.ParseViolationPolicy(*onViolation)
+	if err != nil {
+		writeString(os.Stderr, err.Error())
+		os.Exit(1)
 	}
 
+	format, err := sequence.ParseFormat(*sequenceFormat)
+	if err != nil {
+		writeString(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	isChandyMisra := flag.Arg(0) == "chandymisra" || flag.Arg(0) == "cm"
+	if *graphDir != "" && !isChandyMisra {
+		writeString(os.Stderr, "-graph-dir only applies to chandymisra")
+		os.Exit(1)
+	}
+
+	t, err := shared.NewTable(*nPhils, shared.DiscardOutput{})
+	if err != nil {
+		writeString(os.Stderr, err.Error())
+		os.Exit(3)
+	}
+
+	t.Policy = policy
+	flag.Visit(func(fl *flag.Flag) {
+		if fl.Name == "seed" {
+			t.Seed = *seed
+		}
+	})
+
+	stats := shared.NewStats(t.Names)
+	t.Sinks = append(t.Sinks, stats)
+
+	if *trace != "" {
+		sink, err := openTrace(*trace, shared.NewTraceHeader(t, implementationName(flag.Arg(0))))
+		if err != nil {
+			writeString(os.Stderr, err.Error())
+			os.Exit(3)
+		}
+		defer closeTrace(sink)
+		t.Sinks = append(t.Sinks, sink)
+	}
+
+	detector := monitor.NewDeadlockDetector(t, nil)
+	defer detector.Stop()
+	t.Sinks = append(t.Sinks, detector)
+
+	watchdog := monitor.NewStarvationWatchdog(t)
+	watchdog.MaxWait = *starveWait
+	watchdog.MaxNeighborMeals = *starveMeals
+	switch flag.Arg(0) {
+	case "drinking", "dp":
+		watchdog.Neighbors = func(id int) []int { return drinking.TableGraph(t).Neighbors(id) }
+	}
+	t.Sinks = append(t.Sinks, watchdog)
+
+	safety := newSafetyMonitor(t, flag.Arg(0))
+	if safety != nil {
+		t.Sinks = append(t.Sinks, safety)
+	}
+
+	// Chandy-Misra's precedence graph can be written out with the graph command, or after every state change
+	var graph *monitor.PrecedenceGraph
+	graphFailed := func() error { return nil }
+	if isChandyMisra {
+		graph = monitor.NewPrecedenceGraph(t)
+		t.Sinks = append(t.Sinks, graph)
+		if *graphDir != "" {
+			graphFailed = snapshotGraphs(graph, *graphDir)
+		}
+	}
+	defer func() {
+		if err := graphFailed(); err != nil {
+			writeString(os.Stderr, "error writing precedence graphs: "+err.Error()+"\n")
+		}
+	}()
+
+	if *sequencePath != "" {
+		recorder := sequence.NewRecorder(t, sequence.Window{From: *sequenceFrom, To: *sequenceTo})
+		t.Delivered = recorder.Deliver
+		defer writeSequence(recorder, *sequencePath, format)
+	}
+
+	if *virtual || *headless {
+		if runHeadless(t, f, flag.Arg(0), *duration, *virtual, *asJSON, stats, detector, watchdog, safety) {
+			exitStatus = 5
+		}
+		return
+	}
+
+	sc := screen.Initialize()
+	out := shared.NewHighlightOutput(shared.ScreenOutput{Screen: sc, Row: shared.ScreenPos})
+	t.Output = out
+	sc.WriteScreenLine(1, 1, fmt.Sprintf("%s: %d philosophers, seed %d", flag.Arg(0), t.NPhils, t.Seed))
+	detector.Report = func(d monitor.Deadlock) {
+		sc.WriteScreenLine(2, 1, "DEADLOCK: "+d.String())
+	}
+	watchdog.Report = func(s monitor.Starvation) { out.Highlight(s.Philosopher, true) }
+	watchdog.Fed = func(id int) { out.Highlight(id, false) }
+	if safety != nil {
+		safety.Report = func(v monitor.SafetyViolation) {
+			sc.WriteScreenLine(2, 1, screen.Highlight("UNSAFE: "+v.String()))
+		}
+	}
+	notice := func(s string) { sc.WriteScreenLine(shared.NoticeLine(t.NPhils), 1, s) }
+	t.Gate.Executed = func(r shared.Receiver, m shared.Message) {
+		who := fmt.Sprint(r)
+		if p, ok := r.(shared.Philosopher); ok {
+			who = fmt.Sprintf("%s (%d)", t.Names[p.GetID()], p.GetID())
+		}
+		notice(fmt.Sprintf("%s executed %s", who, m))
+	}
+	t.Violated = func(v shared.Violation) { notice(screen.Highlight(v.String())) }
+	// A panic - with -on-violation panic - is handed over, so that the terminal can be put back before panicking here
+	panics := make(chan interface{}, 1)
+	t.Panicked = func(v interface{}) {
+		select {
+		case panics <- v:
+		default:
+		}
+	}
+	t.Seat(f)
+	ctx, stopTable := context.WithCancel(context.Background())
+	t.Run(ctx)
+	stopStats := showStats(sc, stats, shared.StatsLine(t.NPhils))
+
+	con := &console.Console{Table: t, Stats: stats, Precedence: graph, Show: infoArea(sc, shared.InfoLine(t.NPhils)),
+		Notify: notice}
+	prompts, commands := readCommands(sc, shared.PromptLine(t.NPhils))
+	prompts <- ""
+	halted := false
+	var panicked interface{}
+commands:
 	for {
-		cmd := shared.ReadCmd()
-		if cmd == "q" || cmd == "Q" {
-			break
+		select {
+		case cmd := <-commands:
+			err := con.Execute(cmd)
+			if err == console.ErrQuit {
+				break commands
+			}
+			message := ""
+			if err != nil {
+				message = "error: " + err.Error()
+			}
+			prompts <- message
+		case <-t.Halted():
+			halted = true
+			break commands
+		case panicked = <-panics:
+			break commands
 		}
 	}
 
-	// We are done
-	screen.ClearScreen()
+	// We are done. Once everything that writes to the screen has stopped, the screen can be closed. A halted table is
+	// left frozen, so that its state can be dumped as it was when the violation was found, and one that panicked can't
+	// be relied on to stop
+	if !halted && panicked == nil {
+		notice("stopping - waiting for everyone to finish eating")
+		err = shutdown(t, stopTable)
+	}
+	stopStats()
+	detector.Stop()
+	watchdog.Stop()
+	if safety != nil {
+		safety.Stop()
+	}
+	sc.ClearScreen()
+	sc.PositionCursor(1, 1)
+	sc.Close()
+	writeReport(stats)
+	writeViolations(t)
+	writeSafety(safety)
+	if err != nil {
+		writeString(os.Stderr, err.Error()+"\n")
+	}
+	if panicked != nil {
+		panic(panicked)
+	}
 }
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/shared"
+	"testing"
+)
+
+func TestDefaultNPhils(t *testing.T) {
+	// Setenv puts the variable back as it was at the end of the test
+	t.Setenv(nPhilsVar, "")
+	assert.Equal(t, shared.DefaultNPhils, defaultNPhils())
+	t.Setenv(nPhilsVar, "12")
+	assert.Equal(t, 12, defaultNPhils())
+
+	// -n overrides the environment
+	fs := flag.NewFlagSet("diningphils", flag.ContinueOnError)
+	n := nPhilsFlag(fs)
+	assert.NoError(t, fs.Parse([]string{"-n", "7"}))
+	assert.Equal(t, 7, *n)
+	fs = flag.NewFlagSet("diningphils", flag.ContinueOnError)
+	n = nPhilsFlag(fs)
+	assert.NoError(t, fs.Parse(nil))
+	assert.Equal(t, 12, *n)
+}
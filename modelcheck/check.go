@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared"
+)
+
+// result is the outcome of a check
+type result struct {
+	States   int      // The number of distinct states explored
+	Complete bool     // True if every reachable state was explored
+	Failure  string   // What went wrong, or "" if nothing did
+	Trace    []string // The steps leading to the failure
+	Snapshot shared.Snapshot
+}
+
+// check explores every reachable state of a table of n philosophers created by f, breadth first, looking for a broken
+// invariant or a deadlock - a state in which nothing can happen. Exploring breadth first means any counterexample
+// found is as short as possible. Each state is reached by replaying the steps leading to it on a fresh table, since
+// there is no way to copy one. At most maxStates states are explored.
+func check(f shared.Factory, n int, setup func(t *shared.Table), maxStates int) result {
+	// A state still to be explored, and the steps that can be taken from it
+	type pending struct {
+		path    []step
+		enabled []step
+	}
+	initial := replay(f, n, setup, nil)
+	seen := map[string]bool{initial.fingerprint(): true}
+	queue := []pending{{enabled: initial.enabled()}}
+
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		for _, s := range from.enabled {
+			path := append(append([]step{}, from.path...), s)
+			m := replay(f, n, setup, path)
+			if failure := m.failure(); failure != "" {
+				return m.result(len(seen), failure)
+			}
+			fp := m.fingerprint()
+			if seen[fp] {
+				continue
+			}
+			seen[fp] = true
+			enabled := m.enabled()
+			if len(enabled) == 0 {
+				return m.result(len(seen), "deadlock - nothing can happen")
+			}
+			if len(seen) >= maxStates {
+				return result{States: len(seen)}
+			}
+			queue = append(queue, pending{path: path, enabled: enabled})
+		}
+	}
+	return result{States: len(seen), Complete: true}
+}
+
+// The result of a check that failed in this model's state
+func (m *model) result(states int, failure string) result {
+	return result{
+		States:   states,
+		Complete: true,
+		Failure:  failure,
+		Trace:    m.taken,
+		Snapshot: m.table.Snapshot(),
+	}
+}
+
+// String implements the Stringer interface
+func (r result) String() string {
+	switch {
+	case r.Failure != "":
+		return fmt.Sprintf("FAILED after exploring %d states: %s", r.States, r.Failure)
+	case r.Complete:
+		return fmt.Sprintf("passed - all %d reachable states are safe, and none is deadlocked", r.States)
+	default:
+		return fmt.Sprintf("no failures in the first %d states explored - the check is incomplete", r.States)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/fingers"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"testing"
+)
+
+// sleeper is a philosopher that gets hungry as soon as it starts, and waits for a message that never comes
+type sleeper struct {
+	*shared.PhilosopherBase
+}
+
+func (s sleeper) Start()                   { s.SetState(philstate.Hungry) }
+func (s sleeper) Execute(m shared.Message) {}
+
+func sleeperFactory(t *shared.Table, params shared.CreateParams) (shared.Philosopher, shared.Fork) {
+	return sleeper{&shared.PhilosopherBase{Table: t, ID: params.ID, MessageChan: make(chan shared.Message)}},
+		&shared.ForkBase{ID: params.ID}
+}
+
+func TestChandyMisra(t *testing.T) {
+	for _, n := range []int{2, 3} {
+		r := check(chandymisra.Factory, n, nil, 100000)
+		assert.True(t, r.Complete, n)
+		assert.Empty(t, r.Failure, n)
+		assert.Greater(t, r.States, 10, n)
+	}
+}
+
+func TestCounterexamples(t *testing.T) {
+	// Fingers don't bother with forks, so two neighbors getting hungry one after the other both eat
+	r := check(fingers.Factory, 3, nil, 100000)
+	assert.Equal(t, "philosophers 1 and 0 are neighbors, and both eating", r.Failure)
+	assert.Len(t, r.Trace, 6)
+	assert.Equal(t, []philstate.Enum{philstate.Eating, philstate.Eating, philstate.Inactive}, r.Snapshot.States)
+
+	// Chandy-Misra needs an acyclic precedence graph to start with
+	r = check(chandymisra.Factory, 3, cyclicSetup, 100000)
+	assert.Equal(t, "the precedence graph has a cycle [0 1 2 0]", r.Failure)
+	assert.Len(t, r.Trace, 3)
+
+	r = check(sleeperFactory, 2, nil, 100000)
+	assert.Equal(t, "deadlock - nothing can happen", r.Failure)
+	assert.Equal(t, []string{"Hannah Arendt (0) starts", "Judith Butler (1) starts"}, r.Trace)
+}
+
+func TestMaxStates(t *testing.T) {
+	r := check(chandymisra.Factory, 3, nil, 100)
+	assert.False(t, r.Complete)
+	assert.Equal(t, 100, r.States)
+}
@@ -0,0 +1,95 @@
+// Modelcheck exhaustively checks an algorithm on a small table. It drives the philosophers' Start and Execute itself,
+// one step at a time, and explores every order in which messages can be executed and timers can fire. Every state
+// reached is checked by the safety monitor - no two neighbors eating, forks held by one neighbor at a time, and an
+// acyclic precedence graph - and for deadlock. If a check fails, the shortest sequence of steps leading to it is
+// printed.
+//
+// Only algorithms whose philosophers never wait inside Execute can be driven this way - those that wait on a fork or a
+// waiter need goroutines.
+//
+// Usage:
+//
+//	go run ./modelcheck [-n <philosophers>] [-max-states <n>] [-cyclic] <impl>
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/fingers"
+	"github.com/wizardpb/diningphils-go/shared"
+	"os"
+)
+
+// factoryFor returns the Factory for the named implementation, or nil if it can't be checked
+func factoryFor(impl string) shared.Factory {
+	switch impl {
+	case "chandymisra", "cm":
+		return chandymisra.Factory
+	case "fingers", "f":
+		return fingers.Factory
+	default:
+		return nil
+	}
+}
+
+// cyclicSetup deals the Chandy-Misra forks symmetrically - everybody holds their left fork, and asks for their right
+// one. Every fork is dirty, so everybody gives way to the neighbor on their left, all the way round: the precedence
+// graph is a cycle
+func cyclicSetup(t *shared.Table) {
+	for i, p := range t.Philosophers {
+		t.Forks[i].SetHolder(i)
+		links := p.(*chandymisra.Philosopher).Links
+		links[0].Request, links[1].Request = false, true
+	}
+}
+
+func main() {
+	n := flag.Int("n", 3, "number of philosophers at the table")
+	maxStates := flag.Int("max-states", 1000000, "give up after exploring this many states")
+	cyclic := flag.Bool("cyclic", false, "start Chandy-Misra with everyone holding their left fork, instead of the "+
+		"acyclic arrangement it needs")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] chandymisra|fingers\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	f := factoryFor(flag.Arg(0))
+	if f == nil {
+		fmt.Fprintf(os.Stderr, "%s can't be model checked\n", flag.Arg(0))
+		os.Exit(2)
+	}
+	if *n < shared.MinNPhils {
+		fmt.Fprintf(os.Stderr, "need at least %d philosophers\n", shared.MinNPhils)
+		os.Exit(3)
+	}
+	var setup func(t *shared.Table)
+	if *cyclic {
+		if impl := flag.Arg(0); impl != "chandymisra" && impl != "cm" {
+			fmt.Fprintln(os.Stderr, "-cyclic only applies to chandymisra")
+			os.Exit(1)
+		}
+		setup = cyclicSetup
+	}
+
+	fmt.Printf("%s: %d philosophers\n", flag.Arg(0), *n)
+	r := check(f, *n, setup, *maxStates)
+	fmt.Println(r)
+	if r.Failure == "" {
+		return
+	}
+	fmt.Println("counterexample:")
+	for i, s := range r.Trace {
+		fmt.Printf("%4d  %s\n", i+1, s)
+	}
+	fmt.Println("table state:")
+	if _, err := r.Snapshot.WriteTo(os.Stdout); err != nil {
+		os.Exit(4)
+	}
+	os.Exit(5)
+}
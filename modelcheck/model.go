@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/monitor"
+	"github.com/wizardpb/diningphils-go/shared"
+	"sort"
+	"strings"
+	"time"
+)
+
+// How many messages a philosopher's channel can hold. Nothing reads the channels while a step runs, so they must be big
+// enough never to block a sender
+const channelSize = 64
+
+// Every philosopher embeds a PhilosopherBase
+type based interface {
+	Base() *shared.PhilosopherBase
+}
+
+// The kinds of step that can be taken next
+type stepKind int
+
+const (
+	startStep   stepKind = iota // a philosopher starts
+	deliverStep                 // a philosopher executes the next message in its queue
+	fireStep                    // a pending timer fires, sending its message
+)
+
+// step is one choice made by the scheduler. For a fireStep, id is the index of the timer in the model's pending
+// timers; otherwise it is the philosopher
+type step struct {
+	kind stepKind
+	id   int
+}
+
+// A pending timer: when it fires, message m is sent to philosopher to
+type timer struct {
+	model *model
+	to    int
+	m     shared.Message
+}
+
+// Stop implements the shared.Timer interface
+func (tm *timer) Stop() bool {
+	for i, pending := range tm.model.timers {
+		if pending == tm {
+			tm.model.timers = append(tm.model.timers[:i:i], tm.model.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// stepClock is the Clock for a model. Time is counted in steps, and timers fire whenever the scheduler chooses
+type stepClock struct {
+	model *model
+}
+
+// Now implements the shared.Clock interface
+func (c stepClock) Now() time.Duration {
+	return time.Duration(len(c.model.taken))
+}
+
+// AfterFunc implements the shared.Clock interface. Every timer set by a philosopher sends it a message, so the timer
+// is made straight away, into the empty channels, to find out what the message is - and then held until it fires
+func (c stepClock) AfterFunc(_ time.Duration, f func()) shared.Timer {
+	m := c.model
+	m.collect()
+	f()
+	for i, p := range m.table.Philosophers {
+		select {
+		case msg := <-p.Messages():
+			tm := &timer{model: m, to: i, m: msg}
+			m.timers = append(m.timers, tm)
+			return tm
+		default:
+		}
+	}
+	panic("a timer sent no message")
+}
+
+// Busy implements the shared.Clock interface. There is no work in progress between steps
+func (c stepClock) Busy() {}
+
+// Done implements the shared.Clock interface
+func (c stepClock) Done() {}
+
+// model is a table whose philosophers are driven one step at a time, by the scheduler rather than goroutines. Every
+// message sent is queued for its receiver, and only executed when the scheduler delivers it; every timer is held
+// until the scheduler fires it.
+type model struct {
+	table   *shared.Table
+	started []bool
+	queues  [][]shared.Message
+	timers  []*timer
+	safety  *monitor.SafetyMonitor
+	taken   []string // descriptions of the steps taken so far
+}
+
+// newModel seats a table of n philosophers created by f, and sets it up with setup, if it is not nil
+func newModel(f shared.Factory, n int, setup func(t *shared.Table)) *model {
+	t, err := shared.NewTable(n, shared.DiscardOutput{})
+	if err != nil {
+		panic(err)
+	}
+	m := &model{table: t, started: make([]bool, n), queues: make([][]shared.Message, n)}
+	t.Clock = stepClock{model: m}
+	t.Policy = shared.LogViolation
+	m.safety = monitor.NewSafetyMonitor(t)
+	m.safety.Precedence = true
+	m.safety.History = 0
+	t.Sinks = append(t.Sinks, m.safety)
+
+	t.Seat(f)
+	for _, p := range t.Philosophers {
+		p.(based).Base().MessageChan = make(chan shared.Message, channelSize)
+	}
+	if setup != nil {
+		setup(t)
+	}
+	return m
+}
+
+// replay creates a model, and takes the given steps
+func replay(f shared.Factory, n int, setup func(t *shared.Table), path []step) *model {
+	m := newModel(f, n, setup)
+	for _, s := range path {
+		m.take(s)
+	}
+	return m
+}
+
+// Move the messages sent since the last step from the channels to the queues
+func (m *model) collect() {
+	for i, p := range m.table.Philosophers {
+		for more := true; more; {
+			select {
+			case msg := <-p.Messages():
+				m.queues[i] = append(m.queues[i], msg)
+			default:
+				more = false
+			}
+		}
+	}
+}
+
+// enabled returns the steps that can be taken next. A philosopher executes messages only once it has started
+func (m *model) enabled() []step {
+	steps := []step{}
+	for i, started := range m.started {
+		switch {
+		case !started:
+			steps = append(steps, step{kind: startStep, id: i})
+		case len(m.queues[i]) > 0:
+			steps = append(steps, step{kind: deliverStep, id: i})
+		}
+	}
+	for i := range m.timers {
+		steps = append(steps, step{kind: fireStep, id: i})
+	}
+	return steps
+}
+
+// take takes step s
+func (m *model) take(s step) {
+	var description string
+	switch s.kind {
+	case startStep:
+		description = fmt.Sprintf("%s (%d) starts", m.table.Names[s.id], s.id)
+		m.started[s.id] = true
+		m.table.Philosophers[s.id].Start()
+	case deliverStep:
+		msg := m.queues[s.id][0]
+		m.queues[s.id] = m.queues[s.id][1:]
+		description = fmt.Sprintf("%s (%d) executes %s", m.table.Names[s.id], s.id, msg)
+		m.table.Philosophers[s.id].Execute(msg)
+	case fireStep:
+		tm := m.timers[s.id]
+		m.timers = append(m.timers[:s.id:s.id], m.timers[s.id+1:]...)
+		description = fmt.Sprintf("timer fires for %s (%d): %s", m.table.Names[tm.to], tm.to, tm.m)
+		m.queues[tm.to] = append(m.queues[tm.to], tm.m)
+	}
+	m.collect()
+	m.taken = append(m.taken, description)
+}
+
+// failure returns a description of any invariant broken so far - reported by a philosopher, or found by the safety
+// monitor - or "" if there is none
+func (m *model) failure() string {
+	if vs := m.table.Violations(); len(vs) > 0 {
+		return vs[0].String()
+	}
+	if v, found := m.safety.Found(); found {
+		return v.Message
+	}
+	return ""
+}
+
+// fingerprint describes the whole state of the model, so that states reached by different paths can be recognized as
+// the same. Pending timers are sorted, since the order they were set in makes no difference
+func (m *model) fingerprint() string {
+	var b strings.Builder
+	for i, p := range m.table.Philosophers {
+		fmt.Fprintf(&b, "%d %t %s %v|", i, m.started[i], p.GetState(), m.queues[i])
+		if cm, ok := p.(*chandymisra.Philosopher); ok {
+			for _, l := range cm.Links {
+				fmt.Fprintf(&b, "%t ", l.Request)
+			}
+			b.WriteString("|")
+		}
+	}
+	for _, f := range m.table.Forks {
+		fmt.Fprintf(&b, "%d|", f.Holder())
+		if cm, ok := f.(*chandymisra.Fork); ok {
+			fmt.Fprintf(&b, "%t|", cm.Dirty)
+		}
+	}
+	timers := []string{}
+	for _, tm := range m.timers {
+		timers = append(timers, fmt.Sprintf("%d %s", tm.to, tm.m))
+	}
+	sort.Strings(timers)
+	b.WriteString(strings.Join(timers, "|"))
+	return b.String()
+}
@@ -0,0 +1,221 @@
+package monitor
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultGrace is how long a wait-for cycle must last before it is reported as a deadlock
+const DefaultGrace = 100 * time.Millisecond
+
+// Deadlock describes a cycle in the wait-for graph: each philosopher is hungry, and waiting for a fork held by the
+// next one, with the last waiting for the first
+type Deadlock struct {
+	Time         time.Duration // When the cycle formed
+	Philosophers []int
+	Forks        []int // Forks[i] is the fork Philosophers[i] is waiting for
+}
+
+// String implements the Stringer interface
+func (d Deadlock) String() string {
+	steps := []string{}
+	for i, p := range d.Philosophers {
+		steps = append(steps, fmt.Sprintf("%d waits for fork %d", p, d.Forks[i]))
+	}
+	return fmt.Sprintf("deadlock at %v: %s", d.Time, strings.Join(steps, ", "))
+}
+
+// DeadlockDetector watches a Table for deadlock. It is an EventSink: every event wakes a monitor goroutine which
+// builds the wait-for graph, from fork ownership and philosopher states, and looks for a cycle.
+//
+// A cycle is a deadlock only if it lasts - some algorithms (Chandy-Misra, Lehmann-Rabin) can briefly form a cycle of
+// hungry philosophers holding forks, which they then break by giving a fork up. So a cycle is reported only if it is
+// still there Grace later (by the Table's Clock), and each cycle is reported once.
+type DeadlockDetector struct {
+	table    *shared.Table
+	Grace    time.Duration
+	Report   func(d Deadlock) // Called from the monitor goroutine when a deadlock is found
+	wake     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	lock     sync.Mutex
+	found    []Deadlock
+	suspect  string // the cycle currently forming, as a key
+	since    time.Duration
+	reported bool
+}
+
+// NewDeadlockDetector creates a detector for Table t, and starts its monitor goroutine
+func NewDeadlockDetector(t *shared.Table, report func(d Deadlock)) *DeadlockDetector {
+	d := &DeadlockDetector{
+		table:  t,
+		Grace:  DefaultGrace,
+		Report: report,
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Record implements the EventSink interface - anything happening at the table may form or break a cycle
+func (d *DeadlockDetector) Record(shared.Event) {
+	d.poke()
+}
+
+// Stop stops the monitor goroutine, waiting for any check in progress to finish
+func (d *DeadlockDetector) Stop() {
+	d.stopOnce.Do(func() { close(d.stop) })
+	<-d.done
+}
+
+// Found returns the deadlocks found so far
+func (d *DeadlockDetector) Found() []Deadlock {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return append([]Deadlock{}, d.found...)
+}
+
+// Wake the monitor goroutine. A check is work for the Table's Clock, so that a virtual clock waits for it; if a check
+// is already pending this one is redundant and is dropped
+func (d *DeadlockDetector) poke() {
+	d.table.Clock.Busy()
+	select {
+	case d.wake <- struct{}{}:
+	default:
+		d.table.Clock.Done()
+	}
+}
+
+// The monitor goroutine
+func (d *DeadlockDetector) run() {
+	defer close(d.done)
+	for {
+		select {
+		case <-d.wake:
+			d.check()
+			d.table.Clock.Done()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// check looks for a cycle, and reports it if it has lasted long enough
+func (d *DeadlockDetector) check() {
+	now := d.table.Clock.Now()
+	cycle := d.findCycle()
+
+	d.lock.Lock()
+	var report *Deadlock
+	switch {
+	case cycle == nil:
+		d.suspect = ""
+	case cycle.key() != d.suspect:
+		// A new cycle - look again once the grace period is over
+		d.suspect, d.since, d.reported = cycle.key(), now, false
+		d.table.Clock.AfterFunc(d.Grace, d.poke)
+	case !d.reported && now-d.since >= d.Grace:
+		d.reported = true
+		cycle.Time = d.since
+		d.found = append(d.found, *cycle)
+		report = cycle
+	}
+	d.lock.Unlock()
+
+	if report != nil && d.Report != nil {
+		d.Report(*report)
+	}
+}
+
+// A cycle's key identifies it regardless of where it starts
+func (dl *Deadlock) key() string {
+	phils := append([]int{}, dl.Philosophers...)
+	forks := append([]int{}, dl.Forks...)
+	sort.Ints(phils)
+	sort.Ints(forks)
+	return fmt.Sprint(phils, forks)
+}
+
+// forkSharer is implemented by philosophers that share more forks than the two to their left and right - the drinking
+// philosophers share one with every neighbor on their conflict graph. Forks returns all of them, and is safe to call
+// from any goroutine
+type forkSharer interface {
+	Forks() []shared.Fork
+}
+
+// findCycle builds the wait-for graph and returns a cycle in it, or nil. There is an edge from philosopher p to q when
+// p is hungry and q holds one of the forks p shares with its neighbors
+func (d *DeadlockDetector) findCycle() *Deadlock {
+	t := d.table
+	n := t.NPhils
+	type edge struct{ to, fork int }
+	waitsFor := make([][]edge, n)
+	for id, p := range t.Philosophers {
+		if p.GetState() != philstate.Hungry {
+			continue
+		}
+		forks := []shared.Fork{t.Forks[id], t.Forks[(id+1)%n]}
+		if fs, ok := p.(forkSharer); ok {
+			forks = fs.Forks()
+		}
+		for _, f := range forks {
+			if h := f.Holder(); h != shared.UnOwned && h != id {
+				waitsFor[id] = append(waitsFor[id], edge{to: h, fork: f.GetID()})
+			}
+		}
+	}
+
+	// Depth first search, tracking the path so the cycle can be reported
+	const (
+		unvisited = iota
+		onPath
+		finished
+	)
+	mark := make([]int, n)
+	var path []int
+	var forks []int
+	var visit func(p int) *Deadlock
+	visit = func(p int) *Deadlock {
+		mark[p] = onPath
+		path = append(path, p)
+		for _, e := range waitsFor[p] {
+			forks = append(forks, e.fork)
+			switch mark[e.to] {
+			case onPath:
+				// Found one - it starts where the path first reached e.to
+				start := 0
+				for path[start] != e.to {
+					start++
+				}
+				return &Deadlock{
+					Philosophers: append([]int{}, path[start:]...),
+					Forks:        append([]int{}, forks[start:]...),
+				}
+			case unvisited:
+				if dl := visit(e.to); dl != nil {
+					return dl
+				}
+			}
+			forks = forks[:len(forks)-1]
+		}
+		path = path[:len(path)-1]
+		mark[p] = finished
+		return nil
+	}
+	for p := 0; p < n; p++ {
+		if mark[p] == unvisited {
+			if dl := visit(p); dl != nil {
+				return dl
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package monitor_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/drinking"
+	"github.com/wizardpb/diningphils-go/lehmannrabin"
+	"github.com/wizardpb/diningphils-go/monitor"
+	"github.com/wizardpb/diningphils-go/naive"
+	"github.com/wizardpb/diningphils-go/resourcehierarchy"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"testing"
+	"time"
+)
+
+// runVirtual runs a table in virtual time with a deadlock detector, and returns what it found
+func runVirtual(f shared.Factory, n int, until time.Duration) (time.Duration, []monitor.Deadlock) {
+	table, _ := shared.NewTable(n, shared.DiscardOutput{})
+	clock := shared.NewVirtualClock()
+	table.Clock = clock
+	table.Seed = 1
+	detector := monitor.NewDeadlockDetector(table, nil)
+	defer detector.Stop()
+	table.Sinks = append(table.Sinks, detector)
+	table.Seat(f)
+	table.Run(context.Background())
+	reached := clock.Run(until)
+	return reached, detector.Found()
+}
+
+func TestDeadlockDetected(t *testing.T) {
+	reached, found := runVirtual(naive.NewFactory(3*time.Second), 3, 24*time.Hour)
+	assert.Less(t, reached, 24*time.Hour, "the naive table should deadlock")
+	if assert.Len(t, found, 1) {
+		d := found[0]
+		assert.ElementsMatch(t, []int{0, 1, 2}, d.Philosophers)
+		assert.ElementsMatch(t, []int{0, 1, 2}, d.Forks)
+		assert.LessOrEqual(t, d.Time, reached)
+	}
+}
+
+func TestNoDeadlock(t *testing.T) {
+	// Chandy-Misra and Lehmann-Rabin briefly form cycles, but break them - only a lasting one is a deadlock
+	for name, f := range map[string]shared.Factory{
+		"resourcehierarchy": resourcehierarchy.Factory,
+		"chandymisra":       chandymisra.Factory,
+		"lehmannrabin":      lehmannrabin.Factory,
+	} {
+		reached, found := runVirtual(f, 3, 10*time.Hour)
+		assert.Equal(t, 10*time.Hour, reached, name)
+		assert.Empty(t, found, name)
+	}
+}
+
+func TestDeadlockOverChord(t *testing.T) {
+	// With this seed the drinking philosophers' conflict graph has a chord, edge 4, between 0 and 2
+	table, _ := shared.NewTable(4, shared.DiscardOutput{})
+	clock := shared.NewVirtualClock()
+	table.Clock = clock
+	table.Seed = 1
+	detector := monitor.NewDeadlockDetector(table, nil)
+	defer detector.Stop()
+	table.Sinks = append(table.Sinks, detector)
+	table.Seat(drinking.Factory)
+	assert.Contains(t, drinking.TableGraph(table), drinking.Edge{ID: 4, U: 0, V: 2})
+
+	// Set up a cycle that goes over the chord: 0 waits for 2, which waits for 1, which waits for 0. Each fork starts
+	// with the lower numbered philosopher, so only the chord's needs to move
+	p0 := table.Philosophers[0].(*drinking.Philosopher)
+	for _, l := range p0.Links {
+		if l.Neighbor == 2 {
+			l.Fork.SetHolder(2)
+		}
+	}
+	for _, p := range table.Philosophers[:3] {
+		p.(*drinking.Philosopher).SetState(philstate.Hungry)
+	}
+	clock.Run(time.Second)
+
+	found := detector.Found()
+	if assert.Len(t, found, 1) {
+		assert.ElementsMatch(t, []int{0, 1, 2}, found[0].Philosophers)
+		assert.ElementsMatch(t, []int{1, 2, 4}, found[0].Forks)
+	}
+}
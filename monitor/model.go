@@ -0,0 +1,230 @@
+package monitor
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+)
+
+// tableModel is a picture of a table built from its event stream alone - philosopher states from state changes, and
+// fork ownership from the forks held at each state change and the forks picked up, put down, sent and received. It
+// assumes the philosophers sit in a ring, each between the forks with its own ID and the next.
+//
+// It also follows the cleanliness of forks, as Chandy-Misra uses it: forks are dirtied by eating, and cleaned when they
+// are sent. Forks first seen at a state change are the ones dealt out at the start, which are dirty.
+type tableModel struct {
+	n       int
+	states  []philstate.Enum
+	holders []int // holders[f] is the philosopher holding fork f, or NoPeer
+	sending []int // sending[f] is the philosopher fork f is on its way to, or NoPeer
+	dirty   []bool
+}
+
+// PrecedenceEdge is an edge of the Chandy-Misra precedence graph: philosopher From has precedence over To, because of
+// the fork they share
+type PrecedenceEdge struct {
+	Fork, From, To int
+}
+
+// newTableModel creates the model of a table of n philosophers, before anything has happened
+func newTableModel(n int) tableModel {
+	m := tableModel{
+		n:       n,
+		states:  make([]philstate.Enum, n),
+		holders: make([]int, n),
+		sending: make([]int, n),
+		dirty:   make([]bool, n),
+	}
+	for f := range m.holders {
+		m.holders[f], m.sending[f] = shared.NoPeer, shared.NoPeer
+	}
+	return m
+}
+
+// Update the model with event e, returning a description of any invariant it breaks.
+func (m *tableModel) apply(e shared.Event) string {
+	id := e.Philosopher
+	switch e.Type {
+	case shared.StateChanged:
+		m.states[id] = e.State
+		// The forks listed are exactly those held
+		for f, h := range m.holders {
+			if h == id && !contains(e.Forks, f) {
+				m.holders[f] = shared.NoPeer
+			}
+		}
+		for _, f := range e.Forks {
+			if m.holders[f] == id {
+				continue
+			}
+			if msg := m.take(id, f); msg != "" {
+				return msg
+			}
+			// Forks first seen at a state change are the ones dealt out at the start, which are dirty
+			m.dirty[f] = true
+		}
+		if e.State == philstate.Eating {
+			for _, n := range m.neighbors(id) {
+				if m.states[n] == philstate.Eating {
+					return fmt.Sprintf("philosophers %d and %d are neighbors, and both eating", id, n)
+				}
+			}
+			for _, f := range e.Forks {
+				m.dirty[f] = true
+			}
+		}
+
+	case shared.ForkPickedUp:
+		for _, f := range e.Forks {
+			if msg := m.take(id, f); msg != "" {
+				return msg
+			}
+		}
+
+	case shared.ForkPutDown:
+		for _, f := range e.Forks {
+			if m.holders[f] != id {
+				return fmt.Sprintf("philosopher %d put down fork %d, held by %s", id, f, m.holder(f))
+			}
+			m.holders[f] = shared.NoPeer
+		}
+
+	case shared.ForkSent:
+		for _, f := range e.Forks {
+			if m.holders[f] != id {
+				return fmt.Sprintf("philosopher %d sent fork %d, held by %s", id, f, m.holder(f))
+			}
+			m.holders[f], m.sending[f], m.dirty[f] = shared.NoPeer, e.Peer, false
+		}
+
+	case shared.ForkReceived:
+		for _, f := range e.Forks {
+			if m.sending[f] != id {
+				return fmt.Sprintf("philosopher %d received fork %d, which wasn't sent to it", id, f)
+			}
+			m.sending[f] = shared.NoPeer
+			if msg := m.take(id, f); msg != "" {
+				return msg
+			}
+		}
+	}
+	return ""
+}
+
+// Philosopher id takes fork f.
+func (m *tableModel) take(id, f int) string {
+	if f != id && f != (id+1)%m.n {
+		return fmt.Sprintf("philosopher %d holds fork %d, which is not next to it", id, f)
+	}
+	if h := m.holders[f]; h != shared.NoPeer && h != id {
+		return fmt.Sprintf("philosopher %d holds fork %d, already held by %d", id, f, h)
+	}
+	if s := m.sending[f]; s != shared.NoPeer && s != id {
+		return fmt.Sprintf("philosopher %d holds fork %d, which is on its way to %d", id, f, s)
+	}
+	m.holders[f] = id
+	return ""
+}
+
+// Describe who holds fork f.
+func (m *tableModel) holder(f int) string {
+	if h := m.holders[f]; h != shared.NoPeer {
+		return fmt.Sprint("philosopher ", h)
+	}
+	return "nobody"
+}
+
+// The neighbors of philosopher id - just one with two philosophers at the table
+func (m *tableModel) neighbors(id int) []int {
+	n := m.n
+	left, right := (id+n-1)%n, (id+1)%n
+	if left == right {
+		return []int{left}
+	}
+	return []int{left, right}
+}
+
+func contains(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// The two philosophers fork f lies between: the one on its left has it as their right fork, and vice versa
+func (m *tableModel) sharing(f int) (left, right int) {
+	return (f + m.n - 1) % m.n, f
+}
+
+// precedence returns the edges of the precedence graph. Each fork gives an edge between the two philosophers sharing
+// it: a clean fork gives its holder precedence, a dirty one gives it to the neighbor, and a fork on its way to a
+// neighbor gives that neighbor precedence. A fork nobody holds yet gives no edge
+func (m *tableModel) precedence() []PrecedenceEdge {
+	edges := []PrecedenceEdge{}
+	for f := range m.holders {
+		left, right := m.sharing(f)
+		var first int
+		switch {
+		case m.sending[f] != shared.NoPeer:
+			first = m.sending[f]
+		case m.holders[f] != shared.NoPeer && m.dirty[f]:
+			first = left + right - m.holders[f]
+		case m.holders[f] != shared.NoPeer:
+			first = m.holders[f]
+		default:
+			continue
+		}
+		edges = append(edges, PrecedenceEdge{Fork: f, From: first, To: left + right - first})
+	}
+	return edges
+}
+
+// findCycle looks for a cycle in a graph of n nodes with the given edges, returning the nodes around it, starting and
+// ending with the same one - or nil if there is none
+func findCycle(n int, edges []PrecedenceEdge) []int {
+	next := make([][]int, n)
+	for _, e := range edges {
+		next[e.From] = append(next[e.From], e.To)
+	}
+
+	// Depth first search, looking for an edge back to a node on the current path
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	marks := make([]int, n)
+	path := []int{}
+	var visit func(p int) []int
+	visit = func(p int) []int {
+		marks[p] = onPath
+		path = append(path, p)
+		for _, q := range next[p] {
+			switch marks[q] {
+			case onPath:
+				for i, r := range path {
+					if r == q {
+						return append(append([]int{}, path[i:]...), q)
+					}
+				}
+			case unvisited:
+				if cycle := visit(q); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		marks[p] = done
+		return nil
+	}
+	for p := 0; p < n; p++ {
+		if marks[p] == unvisited {
+			if cycle := visit(p); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
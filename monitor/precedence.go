@@ -0,0 +1,176 @@
+package monitor
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ForkState is the state of a Chandy-Misra fork, and of the request token that goes with it
+type ForkState struct {
+	Holder       int // The philosopher holding the fork, or NoPeer
+	Sending      int // The philosopher the fork is on its way to, or NoPeer
+	Dirty        bool
+	Token        int // The philosopher holding the request token, or NoPeer
+	TokenSending int // The philosopher the request token is on its way to, or NoPeer
+}
+
+// Precedence is a snapshot of the Chandy-Misra precedence graph
+type Precedence struct {
+	Time   time.Duration
+	Names  []string
+	States []philstate.Enum
+	Forks  []ForkState
+	Edges  []PrecedenceEdge
+	Cycle  []int // The philosophers around a cycle, if there is one - which there never should be
+}
+
+// WriteTo writes the graph in Graphviz DOT format, with a node for each philosopher, and an edge for each fork, from the
+// philosopher with precedence to the other. It implements the io.WriterTo interface
+func (g Precedence) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph precedence {\n")
+	fmt.Fprintf(&b, "\tlabel=\"precedence at %v\";\n", g.Time)
+	fmt.Fprintf(&b, "\tnode [shape=ellipse];\n")
+	for i, name := range g.Names {
+		style := ""
+		switch g.States[i] {
+		case philstate.Eating:
+			style = `, style=filled, fillcolor="palegreen"`
+		case philstate.Hungry:
+			style = `, style=filled, fillcolor="lightsalmon"`
+		}
+		fmt.Fprintf(&b, "\tp%d [label=\"%s (%d)\\n%s\"%s];\n", i, name, i, g.States[i], style)
+	}
+	for _, e := range g.Edges {
+		style := ""
+		if g.inCycle(e) {
+			style = `, color="red", fontcolor="red"`
+		}
+		fmt.Fprintf(&b, "\tp%d -> p%d [label=\"%s\"%s];\n", e.From, e.To, g.describe(e.Fork), style)
+	}
+	b.WriteString("}\n")
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Describe fork f, and where its request token is
+func (g Precedence) describe(f int) string {
+	fs := g.Forks[f]
+	fork := fmt.Sprintf("fork %d ", f)
+	switch {
+	case fs.Sending != shared.NoPeer:
+		fork += fmt.Sprintf("to %d", fs.Sending)
+	case fs.Dirty:
+		fork += "dirty"
+	default:
+		fork += "clean"
+	}
+	token := ""
+	switch {
+	case fs.TokenSending != shared.NoPeer:
+		token = fmt.Sprintf("request to %d", fs.TokenSending)
+	case fs.Token != shared.NoPeer:
+		token = fmt.Sprintf("request at %d", fs.Token)
+	}
+	return fork + `\n` + token
+}
+
+// Is edge e part of the cycle?
+func (g Precedence) inCycle(e PrecedenceEdge) bool {
+	for i := 0; i+1 < len(g.Cycle); i++ {
+		if g.Cycle[i] == e.From && g.Cycle[i+1] == e.To {
+			return true
+		}
+	}
+	return false
+}
+
+// PrecedenceGraph is an EventSink that follows the Chandy-Misra precedence graph, built from the event stream like a
+// SafetyMonitor's model of the table, along with the request token for each fork. A request token starts with the
+// neighbor not holding the fork, and goes to the holder with each request for it.
+type PrecedenceGraph struct {
+	table *shared.Table
+	// OnChange, if set, is called with the graph after every state change. Calls are made one at a time, in order, so
+	// it must not call Graph
+	OnChange     func(g Precedence)
+	lock         sync.Mutex
+	model        tableModel
+	tokens       []int
+	tokenSending []int
+	now          time.Duration
+}
+
+// NewPrecedenceGraph creates a precedence graph for Table t
+func NewPrecedenceGraph(t *shared.Table) *PrecedenceGraph {
+	g := &PrecedenceGraph{
+		table:        t,
+		model:        newTableModel(t.NPhils),
+		tokens:       make([]int, t.NPhils),
+		tokenSending: make([]int, t.NPhils),
+	}
+	for f := range g.tokens {
+		g.tokens[f], g.tokenSending[f] = shared.NoPeer, shared.NoPeer
+	}
+	return g
+}
+
+// Record implements the EventSink interface
+func (g *PrecedenceGraph) Record(e shared.Event) {
+	g.lock.Lock()
+	g.now = e.Time
+	g.model.apply(e)
+	switch e.Type {
+	case shared.ForkRequested:
+		for _, f := range e.Forks {
+			g.tokens[f], g.tokenSending[f] = shared.NoPeer, e.Peer
+		}
+	case shared.ForkRequestReceived:
+		for _, f := range e.Forks {
+			g.tokens[f], g.tokenSending[f] = e.Philosopher, shared.NoPeer
+		}
+	}
+	if e.Type == shared.StateChanged && g.OnChange != nil {
+		g.OnChange(g.graph())
+	}
+	g.lock.Unlock()
+}
+
+// Graph returns the graph as it is now
+func (g *PrecedenceGraph) Graph() Precedence {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.graph()
+}
+
+// Build a snapshot of the graph. The lock must be held
+func (g *PrecedenceGraph) graph() Precedence {
+	m := &g.model
+	p := Precedence{
+		Time:   g.now,
+		Names:  g.table.Names,
+		States: append([]philstate.Enum{}, m.states...),
+		Forks:  make([]ForkState, m.n),
+		Edges:  m.precedence(),
+	}
+	for f := range p.Forks {
+		p.Forks[f] = ForkState{
+			Holder:       m.holders[f],
+			Sending:      m.sending[f],
+			Dirty:        m.dirty[f],
+			Token:        g.tokens[f],
+			TokenSending: g.tokenSending[f],
+		}
+		// Until it has moved, the token is where it was dealt - with the neighbor not holding the fork
+		if g.tokens[f] == shared.NoPeer && g.tokenSending[f] == shared.NoPeer && m.holders[f] != shared.NoPeer {
+			left, right := m.sharing(f)
+			p.Forks[f].Token = left + right - m.holders[f]
+		}
+	}
+	p.Cycle = findCycle(m.n, p.Edges)
+	return p
+}
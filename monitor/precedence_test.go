@@ -0,0 +1,68 @@
+package monitor_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/monitor"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"testing"
+	"time"
+)
+
+func TestPrecedenceGraph(t *testing.T) {
+	table, _ := shared.NewTable(3, shared.DiscardOutput{})
+	clock := shared.NewVirtualClock()
+	table.Clock = clock
+	table.Seed = 1
+	graph := monitor.NewPrecedenceGraph(table)
+	changes := []monitor.Precedence{}
+	graph.OnChange = func(g monitor.Precedence) { changes = append(changes, g) }
+	table.Sinks = append(table.Sinks, graph)
+	table.Seat(chandymisra.Factory)
+	table.Run(context.Background())
+	clock.Run(time.Hour)
+
+	assert.NotEmpty(t, changes)
+	for _, g := range changes {
+		assert.Nil(t, g.Cycle, "at %v", g.Time)
+		for f, fs := range g.Forks {
+			// Every fork dealt out has a request token somewhere
+			if fs.Holder != shared.NoPeer {
+				assert.True(t, fs.Token != shared.NoPeer || fs.TokenSending != shared.NoPeer, "fork %d at %v", f,
+					g.Time)
+			}
+		}
+	}
+
+	g := graph.Graph()
+	assert.Len(t, g.Edges, 3)
+	var b bytes.Buffer
+	_, err := g.WriteTo(&b)
+	assert.NoError(t, err)
+	dot := b.String()
+	assert.Contains(t, dot, "digraph precedence {\n")
+	assert.Contains(t, dot, "p0 [label=\""+table.Names[0]+" (0)")
+	assert.Contains(t, dot, "request ")
+	assert.Regexp(t, `p\d -> p\d \[label="fork \d (dirty|clean|to \d)`, dot)
+}
+
+func TestPrecedenceCycle(t *testing.T) {
+	g := monitor.Precedence{
+		Names:  []string{"A", "B"},
+		States: make([]philstate.Enum, 2),
+		Forks: []monitor.ForkState{
+			{Holder: 1, Sending: shared.NoPeer, Dirty: true, Token: 0, TokenSending: shared.NoPeer},
+			{Holder: 1, Sending: shared.NoPeer, Dirty: false, Token: shared.NoPeer, TokenSending: 0},
+		},
+		Edges: []monitor.PrecedenceEdge{{Fork: 0, From: 0, To: 1}, {Fork: 1, From: 1, To: 0}},
+		Cycle: []int{0, 1, 0},
+	}
+	var b bytes.Buffer
+	_, err := g.WriteTo(&b)
+	assert.NoError(t, err)
+	assert.Contains(t, b.String(), `p0 -> p1 [label="fork 0 dirty\nrequest at 0", color="red", fontcolor="red"];`)
+	assert.Contains(t, b.String(), `p1 -> p0 [label="fork 1 clean\nrequest to 0", color="red", fontcolor="red"];`)
+}
@@ -0,0 +1,108 @@
+package monitor
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared"
+	"sync"
+	"time"
+)
+
+// DefaultHistory is how many events leading up to a safety violation are reported
+const DefaultHistory = 20
+
+// SafetyViolation is a broken global invariant, found by a SafetyMonitor
+type SafetyViolation struct {
+	Time    time.Duration
+	Message string
+	Events  []shared.Event // The events leading up to it, the last one breaking the invariant
+}
+
+// String implements the Stringer interface
+func (v SafetyViolation) String() string {
+	return fmt.Sprintf("safety violation at %v: %s", v.Time, v.Message)
+}
+
+// SafetyMonitor is an EventSink that checks the invariants of the whole table, independently of the philosophers. It
+// builds its own model of the table from the event stream alone, and checks, after every event, that:
+//
+//   - no two neighbors are eating at once
+//   - each fork is held by at most one philosopher
+//   - each fork is held only by one of the two philosophers it lies between
+//   - with Precedence set, the Chandy-Misra precedence graph is acyclic
+//
+// The checks assume the philosophers sit in a ring, each between the forks with its own ID and the next, so they don't
+// apply to the drinking philosophers, whose conflict graph is arbitrary.
+//
+// Only the first violation is reported, with the History events leading up to it - after that the model can't be
+// trusted, and checking stops.
+type SafetyMonitor struct {
+	table      *shared.Table
+	Precedence bool
+	History    int
+	Report     func(v SafetyViolation) // Called when a violation is found
+	lock       sync.Mutex
+	model      tableModel
+	recent     []shared.Event
+	found      *SafetyViolation
+	stopped    bool
+}
+
+// NewSafetyMonitor creates a safety monitor for Table t
+func NewSafetyMonitor(t *shared.Table) *SafetyMonitor {
+	return &SafetyMonitor{
+		table:   t,
+		History: DefaultHistory,
+		model:   newTableModel(t.NPhils),
+	}
+}
+
+// Record implements the EventSink interface
+func (m *SafetyMonitor) Record(e shared.Event) {
+	if e.Type == shared.Starving || e.Type == shared.InvariantViolated {
+		return
+	}
+
+	m.lock.Lock()
+	if m.found != nil || m.stopped {
+		m.lock.Unlock()
+		return
+	}
+	m.recent = append(m.recent, e)
+	if len(m.recent) > m.History {
+		m.recent = m.recent[len(m.recent)-m.History:]
+	}
+	message := m.model.apply(e)
+	if message == "" && m.Precedence {
+		if cycle := findCycle(m.table.NPhils, m.model.precedence()); cycle != nil {
+			message = fmt.Sprintf("the precedence graph has a cycle %v", cycle)
+		}
+	}
+	if message == "" {
+		m.lock.Unlock()
+		return
+	}
+	v := SafetyViolation{Time: e.Time, Message: message, Events: append([]shared.Event{}, m.recent...)}
+	m.found = &v
+	m.lock.Unlock()
+
+	if m.Report != nil {
+		m.Report(v)
+	}
+}
+
+// Stop stops the monitor. Nothing more is checked or reported
+func (m *SafetyMonitor) Stop() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.stopped = true
+}
+
+// Found returns the violation found, if there was one
+func (m *SafetyMonitor) Found() (SafetyViolation, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.found == nil {
+		return SafetyViolation{}, false
+	}
+	return *m.found, true
+}
@@ -0,0 +1,102 @@
+package monitor_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/fingers"
+	"github.com/wizardpb/diningphils-go/footman"
+	"github.com/wizardpb/diningphils-go/lehmannrabin"
+	"github.com/wizardpb/diningphils-go/monitor"
+	"github.com/wizardpb/diningphils-go/resourcehierarchy"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"github.com/wizardpb/diningphils-go/waiter"
+	"testing"
+	"time"
+)
+
+// checkSafety runs a table in virtual time with a safety monitor, and returns what it found
+func checkSafety(f shared.Factory, n int, precedence bool) (monitor.SafetyViolation, bool) {
+	table, _ := shared.NewTable(n, shared.DiscardOutput{})
+	clock := shared.NewVirtualClock()
+	table.Clock = clock
+	table.Seed = 1
+	safety := monitor.NewSafetyMonitor(table)
+	safety.Precedence = precedence
+	table.Sinks = append(table.Sinks, safety)
+	table.Seat(f)
+	table.Run(context.Background())
+	clock.Run(10 * time.Hour)
+	return safety.Found()
+}
+
+func TestSafeAlgorithms(t *testing.T) {
+	for name, f := range map[string]shared.Factory{
+		"resourcehierarchy": resourcehierarchy.Factory,
+		"chandymisra":       chandymisra.Factory,
+		"waiter":            waiter.Factory,
+		"footman":           footman.Factory,
+		"lehmannrabin":      lehmannrabin.Factory,
+	} {
+		for _, n := range []int{2, 5} {
+			v, found := checkSafety(f, n, name == "chandymisra")
+			assert.False(t, found, "%s with %d philosophers: %s", name, n, v)
+		}
+	}
+}
+
+func TestFingersUnsafe(t *testing.T) {
+	v, found := checkSafety(fingers.Factory, 5, false)
+	if assert.True(t, found) {
+		assert.Contains(t, v.Message, "are neighbors, and both eating")
+		last := v.Events[len(v.Events)-1]
+		assert.Equal(t, philstate.Eating, last.State)
+		assert.Equal(t, v.Time, last.Time)
+	}
+}
+
+func TestSafetyMonitor(t *testing.T) {
+	held := func(id int, forks ...int) shared.Event {
+		return shared.Event{Philosopher: id, Type: shared.StateChanged, State: philstate.Thinking, Forks: forks,
+			Peer: shared.NoPeer}
+	}
+	check := func(precedence bool, events ...shared.Event) string {
+		table, _ := shared.NewTable(3, shared.DiscardOutput{})
+		safety := monitor.NewSafetyMonitor(table)
+		safety.Precedence = precedence
+		reported := 0
+		safety.Report = func(monitor.SafetyViolation) { reported++ }
+		table.Sinks = append(table.Sinks, safety)
+		for _, e := range events {
+			table.Emit(e)
+		}
+		v, found := safety.Found()
+		if !found {
+			assert.Zero(t, reported)
+			return ""
+		}
+		assert.Equal(t, 1, reported)
+		assert.Len(t, v.Events, len(events))
+		return v.Message
+	}
+
+	pickUp := shared.Event{Philosopher: 0, Type: shared.ForkPickedUp, Forks: []int{1}, Peer: shared.NoPeer}
+	assert.Equal(t, "", check(false, held(0), pickUp))
+	assert.Equal(t, "philosopher 1 holds fork 1, already held by 0", check(false, held(1, 2), held(0, 1), held(1, 1, 2)))
+	assert.Equal(t, "philosopher 0 holds fork 2, which is not next to it", check(false, held(0, 2)))
+	assert.Equal(t, "philosopher 1 put down fork 1, held by nobody",
+		check(false, shared.Event{Philosopher: 1, Type: shared.ForkPutDown, Forks: []int{1}, Peer: shared.NoPeer}))
+
+	// A fork on its way to a neighbor belongs to nobody else
+	sent := shared.Event{Philosopher: 0, Type: shared.ForkSent, Forks: []int{1}, Peer: 1}
+	received := shared.Event{Philosopher: 1, Type: shared.ForkReceived, Forks: []int{1}, Peer: 0}
+	assert.Equal(t, "", check(true, held(0, 1), sent, received))
+	assert.Equal(t, "philosopher 0 holds fork 1, which is on its way to 1", check(false, held(0, 1), sent, pickUp))
+	assert.Equal(t, "philosopher 1 received fork 1, which wasn't sent to it", check(false, received))
+
+	// Each holds a dirty fork, so each gives way to the neighbor it holds it from - all the way round
+	assert.Equal(t, "", check(true, held(0, 1), held(1, 2)))
+	assert.Equal(t, "the precedence graph has a cycle [0 2 1 0]", check(true, held(0, 1), held(1, 2), held(2, 0)))
+	assert.Equal(t, "", check(false, held(0, 1), held(1, 2), held(2, 0)))
+}
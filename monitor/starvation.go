@@ -0,0 +1,188 @@
+package monitor
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"sync"
+	"time"
+)
+
+// DefaultMaxWait is the default hunger bound for a StarvationWatchdog
+const DefaultMaxWait = time.Minute
+
+// Starvation records a philosopher that was hungry for too long
+type Starvation struct {
+	Time          time.Duration // When the bound was passed
+	Philosopher   int
+	Name          string
+	Since         time.Duration // When the philosopher got hungry
+	NeighborMeals int           // How many times its neighbors ate meanwhile
+}
+
+// String implements the Stringer interface
+func (s Starvation) String() string {
+	return fmt.Sprintf("%s (%d) is starving: hungry for %v, neighbors ate %d times",
+		s.Name, s.Philosopher, s.Time-s.Since, s.NeighborMeals)
+}
+
+// StarvationWatchdog is an EventSink that flags philosophers who stay hungry too long - longer than MaxWait, or while
+// their neighbors eat more than MaxNeighborMeals times between them. Either bound is off when zero.
+//
+// The neighbor bound shows the difference between algorithms best: under Chandy-Misra a hungry philosopher gets a fork
+// from each neighbor after that neighbor's next meal, so it is never overtaken more than a couple of times. The
+// resource hierarchy only guarantees that someone eats - a neighbor who thinks quickly can keep on beating a
+// philosopher to a fork.
+//
+// Neighbors are the philosophers on either side around the table, unless Neighbors is set - the drinking philosophers
+// don't sit in a ring, and are neighbors along the edges of their conflict graph.
+//
+// When a philosopher starves it is reported, and a Starving event is emitted to the table; it is reported again only
+// after it has eaten and got hungry again.
+type StarvationWatchdog struct {
+	table            *shared.Table
+	MaxWait          time.Duration
+	MaxNeighborMeals int
+	Neighbors        func(id int) []int // The neighbors of philosopher id, if not around the table
+	Report           func(s Starvation) // Called when a philosopher starts starving
+	Fed              func(id int)       // Called when a starving philosopher finally eats
+	lock             sync.Mutex
+	phils            []hunger
+	counts           []int
+	stopped          bool
+}
+
+// The hunger of one philosopher
+type hunger struct {
+	hungry   bool
+	since    time.Duration
+	meals    int // neighbor meals since getting hungry
+	starving bool
+	gen      int // counts hungry spells, so a timer can tell if it has fired for the current one
+}
+
+// NewStarvationWatchdog creates a watchdog for Table t with the default bounds
+func NewStarvationWatchdog(t *shared.Table) *StarvationWatchdog {
+	return &StarvationWatchdog{
+		table:   t,
+		MaxWait: DefaultMaxWait,
+		phils:   make([]hunger, t.NPhils),
+		counts:  make([]int, t.NPhils),
+	}
+}
+
+// Record implements the EventSink interface. Only state changes matter
+func (w *StarvationWatchdog) Record(e shared.Event) {
+	if e.Type != shared.StateChanged {
+		return
+	}
+	var starved []Starvation
+	fed := false
+
+	w.lock.Lock()
+	if w.stopped {
+		w.lock.Unlock()
+		return
+	}
+	h := &w.phils[e.Philosopher]
+	switch e.State {
+	case philstate.Hungry:
+		h.gen++
+		*h = hunger{hungry: true, since: e.Time, gen: h.gen}
+		if w.MaxWait > 0 {
+			id, gen := e.Philosopher, h.gen
+			w.table.Clock.AfterFunc(w.MaxWait, func() { w.timeout(id, gen) })
+		}
+	case philstate.Eating:
+		fed = h.starving
+		h.hungry, h.starving = false, false
+		for _, n := range w.neighbors(e.Philosopher) {
+			nh := &w.phils[n]
+			if !nh.hungry {
+				continue
+			}
+			nh.meals++
+			if w.MaxNeighborMeals > 0 && nh.meals > w.MaxNeighborMeals && !nh.starving {
+				starved = append(starved, w.starve(n, e.Time))
+			}
+		}
+	default:
+		h.hungry, h.starving = false, false
+	}
+	w.lock.Unlock()
+
+	if fed && w.Fed != nil {
+		w.Fed(e.Philosopher)
+	}
+	for _, s := range starved {
+		w.report(s)
+	}
+}
+
+// Stop stops the watchdog. Nothing more is reported, and any timeouts still to come do nothing
+func (w *StarvationWatchdog) Stop() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.stopped = true
+}
+
+// Counts returns the number of times each philosopher has starved
+func (w *StarvationWatchdog) Counts() []int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return append([]int{}, w.counts...)
+}
+
+// Called MaxWait after philosopher id got hungry - flag it if it is still in the same hungry spell
+func (w *StarvationWatchdog) timeout(id, gen int) {
+	w.lock.Lock()
+	h := &w.phils[id]
+	if w.stopped || !h.hungry || h.starving || h.gen != gen {
+		w.lock.Unlock()
+		return
+	}
+	s := w.starve(id, w.table.Clock.Now())
+	w.lock.Unlock()
+	w.report(s)
+}
+
+// Mark philosopher id as starving. The lock must be held
+func (w *StarvationWatchdog) starve(id int, now time.Duration) Starvation {
+	h := &w.phils[id]
+	h.starving = true
+	w.counts[id]++
+	return Starvation{
+		Time:          now,
+		Philosopher:   id,
+		Name:          w.table.Names[id],
+		Since:         h.since,
+		NeighborMeals: h.meals,
+	}
+}
+
+// Report a starving philosopher, and emit the event. This must be done without the lock, since the event comes
+// back to Record
+func (w *StarvationWatchdog) report(s Starvation) {
+	w.table.Emit(shared.Event{
+		Philosopher: s.Philosopher,
+		Type:        shared.Starving,
+		State:       philstate.Hungry,
+		Peer:        shared.NoPeer,
+	})
+	if w.Report != nil {
+		w.Report(s)
+	}
+}
+
+// The neighbors of philosopher id - just one with two philosophers around the table
+func (w *StarvationWatchdog) neighbors(id int) []int {
+	if w.Neighbors != nil {
+		return w.Neighbors(id)
+	}
+	n := w.table.NPhils
+	left, right := (id+n-1)%n, (id+1)%n
+	if left == right {
+		return []int{left}
+	}
+	return []int{left, right}
+}
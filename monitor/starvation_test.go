@@ -0,0 +1,86 @@
+package monitor_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/monitor"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"testing"
+	"time"
+)
+
+func stateChange(id int, s philstate.Enum) shared.Event {
+	return shared.Event{Philosopher: id, Type: shared.StateChanged, State: s, Peer: shared.NoPeer}
+}
+
+func TestStarvationWatchdog(t *testing.T) {
+	table, _ := shared.NewTable(3, shared.DiscardOutput{})
+	clock := shared.NewVirtualClock()
+	table.Clock = clock
+	w := monitor.NewStarvationWatchdog(table)
+	w.MaxNeighborMeals = 2
+	starving := []monitor.Starvation{}
+	w.Report = func(s monitor.Starvation) { starving = append(starving, s) }
+	table.Sinks = append(table.Sinks, w)
+
+	// Neighbors eating too often
+	table.Emit(stateChange(0, philstate.Hungry))
+	for i := 0; i < 3; i++ {
+		table.Emit(stateChange(1+i%2, philstate.Eating))
+	}
+	assert.Equal(t, []int{1, 0, 0}, w.Counts())
+	if assert.Len(t, starving, 1) {
+		assert.Equal(t, 0, starving[0].Philosopher)
+		assert.Equal(t, 3, starving[0].NeighborMeals)
+	}
+	table.Emit(stateChange(0, philstate.Eating))
+
+	// Waiting too long - the first hungry spell ends in time, the second doesn't
+	table.Emit(stateChange(1, philstate.Hungry))
+	clock.AfterFunc(time.Second, func() { table.Emit(stateChange(1, philstate.Eating)) })
+	clock.AfterFunc(2*time.Second, func() { table.Emit(stateChange(1, philstate.Hungry)) })
+	clock.Run(time.Hour)
+	assert.Equal(t, []int{1, 1, 0}, w.Counts())
+	if assert.Len(t, starving, 2) {
+		assert.Equal(t, 2*time.Second, starving[1].Since)
+		assert.Equal(t, 2*time.Second+monitor.DefaultMaxWait, starving[1].Time)
+	}
+}
+
+func TestChandyMisraStarvationFree(t *testing.T) {
+	// Each neighbor of a hungry philosopher eats at most once before it does
+	table, _ := shared.NewTable(5, shared.DiscardOutput{})
+	clock := shared.NewVirtualClock()
+	table.Clock = clock
+	table.Seed = 3
+	w := monitor.NewStarvationWatchdog(table)
+	w.MaxWait = 0
+	w.MaxNeighborMeals = 2
+	table.Sinks = append(table.Sinks, w)
+	table.Seat(chandymisra.Factory)
+	table.Run(context.Background())
+	clock.Run(24 * time.Hour)
+	assert.Equal(t, []int{0, 0, 0, 0, 0}, w.Counts())
+}
+
+func TestStarvationWatchdogNeighbors(t *testing.T) {
+	// Philosopher 0 shares forks with 2 only, not with the philosophers either side of it
+	table, _ := shared.NewTable(4, shared.DiscardOutput{})
+	w := monitor.NewStarvationWatchdog(table)
+	w.MaxWait = 0
+	w.MaxNeighborMeals = 1
+	w.Neighbors = func(id int) []int { return map[int][]int{0: {2}, 2: {0}}[id] }
+	table.Sinks = append(table.Sinks, w)
+
+	table.Emit(stateChange(0, philstate.Hungry))
+	for i := 0; i < 3; i++ {
+		table.Emit(stateChange(1, philstate.Eating))
+		table.Emit(stateChange(3, philstate.Eating))
+	}
+	assert.Equal(t, []int{0, 0, 0, 0}, w.Counts())
+	table.Emit(stateChange(2, philstate.Eating))
+	table.Emit(stateChange(2, philstate.Eating))
+	assert.Equal(t, []int{1, 0, 0, 0}, w.Counts())
+}
@@ -0,0 +1,84 @@
+package naive
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/semfork"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"time"
+)
+
+// Philosopher implementation. This is the obvious solution, and it is wrong: everyone picks up their left fork, then
+// their right one. If they all get hungry at about the same time, they all end up holding their left fork and waiting
+// forever for the right one - a deadlock.
+//
+// How likely that is depends on how long a Philosopher holds its left fork before reaching for the right one, so a
+// delay can be put between the two pickups. Without one, a deadlock needs a very unlucky interleaving of goroutines.
+type Philosopher struct {
+	semfork.Philosopher
+	// How long to wait between picking up the left fork and picking up the right one
+	delay time.Duration
+}
+
+// Execute implements the Philosopher interface for the naive implementation. Pick up the left fork then the right one
+// when hungry, and put them back when done
+func (p *Philosopher) Execute(m shared.Message) {
+	switch mt := m.(type) {
+	case shared.NewState:
+		// Update our state value
+		p.SetState(mt.NewState)
+		switch p.State {
+		case philstate.Hungry:
+			p.pickUp(p.LeftFork())
+			if p.delay > 0 {
+				shared.SendIn(p.delay, PickUpMessage{}, p.PhilosopherBase)
+				return
+			}
+			p.pickUp(p.RightFork())
+			p.Eat()
+		case philstate.Thinking:
+			p.PutDown(p.RightFork())
+			p.PutDown(p.LeftFork())
+			p.StartThinking()
+		}
+	case PickUpMessage:
+		p.pickUp(p.RightFork())
+		p.Eat()
+	default:
+		panic("unknown message: " + m.String())
+	}
+}
+
+// Pick up a fork, saying so first - since this is where a deadlocked Philosopher is stuck
+func (p *Philosopher) pickUp(f shared.Fork) {
+	p.WriteString(fmt.Sprintf("waits for fork %d", f.GetID()))
+	p.PickUp(f)
+}
+
+// Factory function for Philosopher and Fork, with no delay between pickups
+func Factory(t *shared.Table, params shared.CreateParams) (shared.Philosopher, shared.Fork) {
+	return NewFactory(0)(t, params)
+}
+
+// NewFactory returns a Factory for Philosophers that wait for delay between picking up their left and right forks
+func NewFactory(delay time.Duration) shared.Factory {
+	return func(t *shared.Table, params shared.CreateParams) (shared.Philosopher, shared.Fork) {
+		p := &Philosopher{
+			Philosopher: semfork.Philosopher{
+				PhilosopherBase: &shared.PhilosopherBase{
+					Table:       t,
+					ID:          params.ID,
+					Name:        params.Name,
+					State:       philstate.Inactive,
+					ThinkRange:  params.ThinkRange,
+					EatRange:    params.EatRange,
+					Rand:        params.Rand,
+					MessageChan: make(chan shared.Message, 0),
+				},
+			},
+			delay: delay,
+		}
+
+		return p, semfork.NewFork(t, params.ID)
+	}
+}
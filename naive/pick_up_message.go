@@ -0,0 +1,9 @@
+package naive
+
+// PickUpMessage is sent by a Philosopher to itself, after picking up its left fork, to go on and pick up the right one
+type PickUpMessage struct{}
+
+// String implements the Stringer interface
+func (m PickUpMessage) String() string {
+	return "Pick up right fork"
+}
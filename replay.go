@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/wizardpb/diningphils-go/console"
+	"github.com/wizardpb/diningphils-go/replay"
+	"github.com/wizardpb/diningphils-go/screen"
+	"github.com/wizardpb/diningphils-go/shared"
+	"os"
+)
+
+// replayMain plays back a trace, given the arguments after the replay subcommand
+func replayMain(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := flags.Float64("speed", 1, "play at this many times the speed of the run")
+	paused := flags.Bool("paused", false, "start paused, at the beginning of the run")
+	flags.Usage = func() {
+		writeString(os.Stderr, fmt.Sprintf("usage: %s replay [flags] <trace file>\n", os.Args[0]))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	if *speed <= 0 {
+		writeString(os.Stderr, "-speed must be positive")
+		os.Exit(1)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		writeString(os.Stderr, err.Error())
+		os.Exit(3)
+	}
+	header, events, err := shared.ReadTrace(f)
+	f.Close()
+	if err != nil {
+		writeString(os.Stderr, fmt.Sprintf("%s: %v", flags.Arg(0), err))
+		os.Exit(3)
+	}
+
+	sc := screen.Initialize()
+	player := replay.NewPlayer(header, events)
+	n := player.NPhils()
+	player.Output = shared.ScreenOutput{Screen: sc, Row: shared.ScreenPos}
+	player.Show = infoArea(sc, shared.InfoLine(n))
+	player.Status = func(s string) { sc.WriteScreenLine(shared.StatsLine(n), 1, s) }
+	player.SetSpeed(*speed)
+	if *paused {
+		player.Pause()
+	}
+
+	sc.WriteScreenLine(1, 1, fmt.Sprintf("replay of %s: %s, %d philosophers, seed %d, %d events", flags.Arg(0),
+		header.Algorithm, n, header.Seed, len(events)))
+	player.Draw()
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		player.Play(ctx)
+	}()
+
+	prompts, commands := readCommands(sc, shared.PromptLine(n))
+	prompts <- ""
+	for cmd := range commands {
+		err := player.Execute(cmd)
+		if err == console.ErrQuit {
+			break
+		}
+		message := ""
+		if err != nil {
+			message = "error: " + err.Error()
+		}
+		prompts <- message
+	}
+
+	stop()
+	<-done
+	sc.ClearScreen()
+	sc.PositionCursor(1, 1)
+	sc.Close()
+}
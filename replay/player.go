@@ -0,0 +1,441 @@
+// Package replay plays back the trace of an earlier run, redrawing the table as it was, without running any algorithm.
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/wizardpb/diningphils-go/console"
+	"github.com/wizardpb/diningphils-go/screen"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often a playing Player moves on
+const tick = 100 * time.Millisecond
+
+// How many events apart the checkpoints are. Seeking goes from the nearest checkpoint, so never has more than this
+// many events to apply
+const checkpointEvery = 1000
+
+// The help text
+var help = []string{
+	"pause                    stop playing",
+	"resume                   carry on playing",
+	"step                     move on to the next event",
+	"speed <factor>           play at factor times the speed of the run",
+	"seek <time>              jump to a time in the run, e.g. 1h30m - or +<time> or -<time> to jump forward or back",
+	"help                     show this help",
+	"q, quit                  quit",
+}
+
+// What a philosopher is doing, as far as the trace shows
+type philosopher struct {
+	state philstate.Enum
+	forks []int
+	last  string // what it did last
+	alert bool   // it is starving, or has found a broken invariant
+}
+
+// checkpoint is the state of every philosopher after the first next events
+type checkpoint struct {
+	next  int
+	phils []philosopher
+}
+
+// Copy the philosophers, so that applying events to one copy doesn't change the other
+func clonePhilosophers(phils []philosopher) []philosopher {
+	c := append([]philosopher{}, phils...)
+	for i := range c {
+		c[i].forks = append([]int{}, c[i].forks...)
+	}
+	return c
+}
+
+// Player plays back the events of a trace, drawing a line for each philosopher like the one shown during the run.
+// Playing moves through the run at Speed times the speed it was recorded at, and can be paused, stepped one event at
+// a time, and moved to any time in the run. It is driven by Play, and by commands given to Execute.
+type Player struct {
+	// Output is where the philosopher lines are drawn
+	Output shared.Output
+	// Show displays the output of a command, such as the help text
+	Show func(lines []string)
+	// Status, if set, shows where playback is up to, and how fast it is going, whenever that changes
+	Status func(s string)
+
+	events      []shared.Event
+	names       []string
+	end         time.Duration
+	checkpoints []checkpoint
+
+	lock   sync.Mutex
+	now    time.Duration
+	next   int // the next event to apply
+	speed  float64
+	paused bool
+	phils  []philosopher
+}
+
+// NewPlayer creates a player for the events of a trace with header h, at the start of the run
+func NewPlayer(h shared.TraceHeader, events []shared.Event) *Player {
+	events = append([]shared.Event{}, events...)
+	// Philosophers run concurrently, so events at the same moment may have been traced out of order
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time < events[j].Time })
+	n := h.NPhils
+	p := &Player{
+		Output: shared.DiscardOutput{},
+		events: events,
+		names:  h.Names,
+		speed:  1,
+		phils:  make([]philosopher, n),
+	}
+	if len(events) > 0 {
+		p.end = events[len(events)-1].Time
+	}
+
+	// Play the whole run through once, to take the checkpoints
+	for p.checkpoint(); p.next < len(events); {
+		p.apply(events[p.next])
+		if p.next++; p.next%checkpointEvery == 0 {
+			p.checkpoint()
+		}
+	}
+	p.next, p.phils = 0, make([]philosopher, n)
+	return p
+}
+
+// Take a checkpoint of where playback is up to
+func (p *Player) checkpoint() {
+	p.checkpoints = append(p.checkpoints, checkpoint{next: p.next, phils: clonePhilosophers(p.phils)})
+}
+
+// The last checkpoint taken at or before time to - the start of the run, if there is no other
+func (p *Player) checkpointAt(to time.Duration) checkpoint {
+	// The first checkpoint after to, of those after the start
+	after := sort.Search(len(p.checkpoints)-1, func(i int) bool {
+		return p.events[p.checkpoints[i+1].next-1].Time > to
+	})
+	return p.checkpoints[after]
+}
+
+// NPhils returns the number of philosophers at the table
+func (p *Player) NPhils() int {
+	return len(p.names)
+}
+
+// End returns the time of the last event in the trace
+func (p *Player) End() time.Duration {
+	return p.end
+}
+
+// Now returns how far through the run playback is
+func (p *Player) Now() time.Duration {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.now
+}
+
+// Draw draws every philosopher, and the status
+func (p *Player) Draw() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.drawAll()
+}
+
+// Play moves through the run in real time until ctx is done
+func (p *Player) Play(ctx context.Context) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Advance(tick)
+		}
+	}
+}
+
+// Advance moves playback on by d, scaled by the speed, unless it is paused. Playback pauses at the end of the run
+func (p *Player) Advance(d time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.paused {
+		return
+	}
+	to := p.now + time.Duration(float64(d)*p.speed)
+	if to >= p.end {
+		to, p.paused = p.end, true
+	}
+	p.seek(to)
+}
+
+// Seek moves playback to time to, forward or back
+func (p *Player) Seek(to time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.seek(to)
+}
+
+// Step moves playback on to the next event. It returns false if there are none left
+func (p *Player) Step() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.next >= len(p.events) {
+		return false
+	}
+	e := p.events[p.next]
+	p.apply(e)
+	p.next++
+	p.now = e.Time
+	p.draw(e.Philosopher)
+	p.drawStatus()
+	return true
+}
+
+// Pause stops playback moving on
+func (p *Player) Pause() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.paused = true
+	p.drawStatus()
+}
+
+// Resume carries on playing
+func (p *Player) Resume() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.paused = false
+	p.drawStatus()
+}
+
+// SetSpeed plays back at factor times the speed of the run
+func (p *Player) SetSpeed(factor float64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.speed = factor
+	p.drawStatus()
+}
+
+// Execute parses and carries out a command line. It returns an error, suitable for showing to the user, if the command
+// is not valid, or console.ErrQuit if it asks to quit
+func (p *Player) Execute(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	cmd, args := fields[0], fields[1:]
+	wantArgs := 0
+	switch cmd {
+	case "speed", "seek":
+		wantArgs = 1
+	}
+	if len(args) != wantArgs {
+		return fmt.Errorf("%s: expected %d arguments, got %d - try help", cmd, wantArgs, len(args))
+	}
+
+	switch cmd {
+	case "q", "Q", "quit":
+		return console.ErrQuit
+
+	case "pause":
+		p.Pause()
+
+	case "resume":
+		p.Resume()
+
+	case "step":
+		if !p.Step() {
+			return errors.New("step: the run is over")
+		}
+
+	case "speed":
+		factor, err := strconv.ParseFloat(args[0], 64)
+		if err != nil || factor <= 0 {
+			return fmt.Errorf("speed: %q is not a positive number", args[0])
+		}
+		p.SetSpeed(factor)
+
+	case "seek":
+		arg := args[0]
+		relative := strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-")
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return fmt.Errorf("seek: %q is not a time, such as 1h30m, +10s or -10s", arg)
+		}
+		if relative {
+			d += p.Now()
+		}
+		p.Seek(d)
+
+	case "help", "?":
+		if p.Show != nil {
+			p.Show(help)
+		}
+
+	default:
+		return fmt.Errorf("unknown command %q - try help", cmd)
+	}
+	return nil
+}
+
+// Move playback to time to, which is kept within the run. Going back, or a long way forward, starts again from the
+// last checkpoint before to. The lock must be held
+func (p *Player) seek(to time.Duration) {
+	if to < 0 {
+		to = 0
+	}
+	if to > p.end {
+		to = p.end
+	}
+	changed := map[int]bool{}
+	if cp := p.checkpointAt(to); to < p.now || cp.next > p.next {
+		p.next = cp.next
+		p.phils = clonePhilosophers(cp.phils)
+		for i := range p.phils {
+			changed[i] = true
+		}
+	}
+	for ; p.next < len(p.events) && p.events[p.next].Time <= to; p.next++ {
+		p.apply(p.events[p.next])
+		changed[p.events[p.next].Philosopher] = true
+	}
+	p.now = to
+	for id := range changed {
+		p.draw(id)
+	}
+	p.drawStatus()
+}
+
+// Update the philosopher involved in event e. The lock must be held
+func (p *Player) apply(e shared.Event) {
+	ph := &p.phils[e.Philosopher]
+	ph.state = e.State
+	ph.last = describe(e)
+	switch e.Type {
+	case shared.StateChanged:
+		ph.forks = append([]int{}, e.Forks...)
+		if e.State == philstate.Eating {
+			ph.alert = false
+		}
+	case shared.ForkPickedUp, shared.ForkReceived:
+		ph.forks = append(ph.forks, e.Forks...)
+	case shared.ForkPutDown, shared.ForkSent:
+		kept := []int{}
+		for _, f := range ph.forks {
+			if !contains(e.Forks, f) {
+				kept = append(kept, f)
+			}
+		}
+		ph.forks = kept
+	case shared.Starving, shared.InvariantViolated:
+		ph.alert = true
+	}
+}
+
+// Draw philosopher id's line. The lock must be held
+func (p *Player) draw(id int) {
+	ph := p.phils[id]
+	forks := ""
+	switch len(ph.forks) {
+	case 0:
+	case 1:
+		forks = fmt.Sprintf(", holds fork %d", ph.forks[0])
+	default:
+		forks = ", holds forks " + joinInts(ph.forks, " and ")
+	}
+	s := fmt.Sprintf("%s (%d,%s) %s%s", p.names[id], id, ph.state, ph.last, forks)
+	if ph.alert {
+		s = screen.Highlight(s)
+	}
+	p.Output.WriteLine(id, s)
+}
+
+// Draw everything. The lock must be held
+func (p *Player) drawAll() {
+	for id := range p.phils {
+		p.draw(id)
+	}
+	p.drawStatus()
+}
+
+// Show where playback is up to. The lock must be held
+func (p *Player) drawStatus() {
+	if p.Status == nil {
+		return
+	}
+	s := fmt.Sprintf("replaying %v of %v, event %d of %d, at %g times speed", p.now, p.end, p.next, len(p.events),
+		p.speed)
+	if p.paused {
+		s += " - paused"
+	}
+	p.Status(s)
+}
+
+// Describe what happened in event e
+func describe(e shared.Event) string {
+	forks, bottles := joinInts(e.Forks, " and "), joinInts(e.Bottles, " and ")
+	switch e.Type {
+	case shared.StateChanged:
+		switch e.State {
+		case philstate.Thinking:
+			return "starts thinking"
+		case philstate.Hungry:
+			return "is hungry"
+		case philstate.Eating:
+			return "starts eating"
+		case philstate.Stopped:
+			return "has stopped"
+		}
+		return "is " + e.State.String()
+	case shared.ForkPickedUp:
+		return "picks up fork " + forks
+	case shared.ForkPutDown:
+		return "puts down fork " + forks
+	case shared.ForkSent:
+		return fmt.Sprintf("sent fork %s to philosopher %d", forks, e.Peer)
+	case shared.ForkReceived:
+		return fmt.Sprintf("receives fork %s from philosopher %d", forks, e.Peer)
+	case shared.ForkRequested:
+		return fmt.Sprintf("requested fork %s from philosopher %d", forks, e.Peer)
+	case shared.ForkRequestReceived:
+		return fmt.Sprintf("received fork request for %s from philosopher %d", forks, e.Peer)
+	case shared.BottleSent:
+		return fmt.Sprintf("sent bottle %s to philosopher %d", bottles, e.Peer)
+	case shared.BottleReceived:
+		return fmt.Sprintf("receives bottle %s from philosopher %d", bottles, e.Peer)
+	case shared.BottleRequested:
+		return fmt.Sprintf("requested bottle %s from philosopher %d", bottles, e.Peer)
+	case shared.BottleRequestReceived:
+		return fmt.Sprintf("received bottle request for %s from philosopher %d", bottles, e.Peer)
+	case shared.Starving:
+		return "is starving"
+	case shared.InvariantViolated:
+		return "found a broken invariant: " + e.Detail
+	}
+	return string(e.Type)
+}
+
+// Join some numbers with sep
+func joinInts(ns []int, sep string) string {
+	s := []string{}
+	for _, n := range ns {
+		s = append(s, strconv.Itoa(n))
+	}
+	return strings.Join(s, sep)
+}
+
+// Is n in ns?
+func contains(ns []int, n int) bool {
+	for _, m := range ns {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package replay_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/console"
+	"github.com/wizardpb/diningphils-go/replay"
+	"github.com/wizardpb/diningphils-go/shared"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lines is an Output that remembers the last thing written to each line
+type lines struct {
+	lock  sync.Mutex
+	lines map[int]string
+}
+
+func (l *lines) WriteLine(line int, s string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines[line] = s
+}
+
+func (l *lines) copy() map[int]string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	c := map[int]string{}
+	for k, v := range l.lines {
+		c[k] = v
+	}
+	return c
+}
+
+// trace runs Chandy-Misra in virtual time for d, and returns the trace it writes
+func trace(t *testing.T, d time.Duration) (shared.TraceHeader, []shared.Event) {
+	var b bytes.Buffer
+	table, _ := shared.NewTable(3, shared.DiscardOutput{})
+	clock := shared.NewVirtualClock()
+	table.Clock = clock
+	table.Seed = 1
+	sink := shared.NewTraceSink(&b, shared.NewTraceHeader(table, "chandymisra"))
+	table.Sinks = append(table.Sinks, sink)
+	table.Seat(chandymisra.Factory)
+	table.Run(context.Background())
+	clock.Run(d)
+	assert.NoError(t, sink.Close())
+	h, events, err := shared.ReadTrace(&b)
+	assert.NoError(t, err)
+	return h, events
+}
+
+func TestPlayer(t *testing.T) {
+	h, events := trace(t, 10*time.Minute)
+	player := replay.NewPlayer(h, events)
+	out := &lines{lines: map[int]string{}}
+	status := ""
+	player.Output = out
+	player.Status = func(s string) { status = s }
+	assert.Equal(t, 3, player.NPhils())
+	assert.Equal(t, events[len(events)-1].Time, player.End())
+
+	// Stepping shows one event at a time
+	assert.True(t, player.Step())
+	assert.Equal(t, events[0].Time, player.Now())
+	assert.Contains(t, status, "event 1 of")
+
+	// Playing moves through the run at the speed given, and stops at the end
+	player.SetSpeed(60)
+	player.Advance(time.Second)
+	assert.Equal(t, time.Minute, player.Now())
+	atMinute := out.copy()
+	player.Advance(time.Hour)
+	assert.Equal(t, player.End(), player.Now())
+	assert.Contains(t, status, "paused")
+
+	// Seeking back shows the table just as it was
+	player.Seek(time.Minute)
+	assert.Equal(t, atMinute, out.copy())
+	assert.Contains(t, atMinute[0], "Hannah Arendt (0,")
+
+	// Paused, playback doesn't move
+	player.Advance(time.Second)
+	assert.Equal(t, time.Minute, player.Now())
+}
+
+func TestSeekCheckpoints(t *testing.T) {
+	// Long enough for plenty of checkpoints
+	h, events := trace(t, 3*time.Hour)
+	assert.Greater(t, len(events), 5000)
+	player := replay.NewPlayer(h, events)
+	out := &lines{lines: map[int]string{}}
+	player.Output = out
+
+	// Wherever playback comes from, the table looks just as it does stepping through every event up to that time
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time < events[j].Time })
+	for _, to := range []time.Duration{2 * time.Hour, 30 * time.Minute, 0, 90 * time.Minute, 3 * time.Hour,
+		time.Hour} {
+		fresh := replay.NewPlayer(h, events)
+		freshOut := &lines{lines: map[int]string{}}
+		fresh.Output = freshOut
+		fresh.Draw()
+		for _, e := range events {
+			if e.Time <= to {
+				fresh.Step()
+			}
+		}
+		player.Seek(to)
+		assert.Equal(t, freshOut.copy(), out.copy(), "at %v", to)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	player := replay.NewPlayer(trace(t, 10*time.Minute))
+	shown := []string{}
+	player.Show = func(lines []string) { shown = lines }
+	for line, msg := range map[string]string{
+		"rewind":     `unknown command "rewind" - try help`,
+		"seek":       "seek: expected 1 arguments, got 0 - try help",
+		"seek later": `seek: "later" is not a time, such as 1h30m, +10s or -10s`,
+		"speed 0":    `speed: "0" is not a positive number`,
+	} {
+		err := player.Execute(line)
+		if assert.Error(t, err, line) {
+			assert.Equal(t, msg, err.Error())
+		}
+	}
+
+	assert.NoError(t, player.Execute("pause"))
+	assert.NoError(t, player.Execute("seek 2m"))
+	assert.NoError(t, player.Execute("seek +30s"))
+	assert.Equal(t, 150*time.Second, player.Now())
+	assert.NoError(t, player.Execute("seek -1m"))
+	assert.Equal(t, 90*time.Second, player.Now())
+	assert.NoError(t, player.Execute("seek 1000h"))
+	assert.Equal(t, player.End(), player.Now())
+	assert.EqualError(t, player.Execute("step"), "step: the run is over")
+	assert.NoError(t, player.Execute("help"))
+	assert.Contains(t, shown[0], "pause")
+	assert.Equal(t, console.ErrQuit, player.Execute("q"))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/wizardpb/diningphils-go/monitor"
+	"github.com/wizardpb/diningphils-go/shared"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Outcomes of a headless run
+const (
+	completed = "completed" // the run lasted as long as it was meant to
+	halted    = "halted"    // a violation halted the table
+	stalled   = "stalled"   // every philosopher was waiting, and nothing was scheduled
+	stuck     = "stuck"     // the table didn't stop when asked to at the end of the run
+)
+
+// runReport is what happened in a headless run, for printing or writing as JSON
+type runReport struct {
+	Algorithm    string             `json:"algorithm"`
+	Philosophers int                `json:"philosophers"`
+	Seed         int64              `json:"seed"`
+	Virtual      bool               `json:"virtual"`
+	Duration     time.Duration      `json:"duration"` // how long the run lasted, by the table's clock, before shutdown
+	Elapsed      time.Duration      `json:"elapsed"`  // how long it took, in real time
+	Outcome      string             `json:"outcome"`
+	Stats        shared.StatsReport `json:"stats"`
+	Messages     map[string]int     `json:"messages"` // how many of each type of message were sent
+	Violations   []string           `json:"violations"`
+	Safety       string             `json:"safety,omitempty"` // the safety violation found, if there was one
+	Deadlocks    []string           `json:"deadlocks"`
+	Starved      []int              `json:"starved"` // how many times each philosopher starved
+}
+
+// newRunReport gathers up what happened at Table t
+func newRunReport(t *shared.Table, impl string, virtual bool, duration, elapsed time.Duration, outcome string,
+	stats *shared.Stats, detector *monitor.DeadlockDetector, watchdog *monitor.StarvationWatchdog,
+	safety *monitor.SafetyMonitor) runReport {
+	r := runReport{
+		Algorithm:    impl,
+		Philosophers: t.NPhils,
+		Seed:         t.Seed,
+		Virtual:      virtual,
+		Duration:     duration,
+		Elapsed:      elapsed,
+		Outcome:      outcome,
+		Stats:        stats.Report(),
+		Messages:     t.MessageCounts(),
+		Violations:   []string{},
+		Deadlocks:    []string{},
+		Starved:      watchdog.Counts(),
+	}
+	for _, v := range t.Violations() {
+		r.Violations = append(r.Violations, v.String())
+	}
+	if safety != nil {
+		if v, found := safety.Found(); found {
+			r.Safety = v.String()
+		}
+	}
+	for _, d := range detector.Found() {
+		r.Deadlocks = append(r.Deadlocks, d.String())
+	}
+	return r
+}
+
+// failed returns true if the run found anything wrong
+func (r runReport) failed() bool {
+	return len(r.Violations) > 0 || r.Safety != "" || len(r.Deadlocks) > 0
+}
+
+// writeJSON writes the report to stdout as JSON
+func (r runReport) writeJSON() {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		os.Exit(4)
+	}
+	writeString(os.Stdout, string(b)+"\n")
+}
+
+// writeMessages prints how many of each type of message were sent
+func writeMessages(counts map[string]int) {
+	types := []string{}
+	total := 0
+	for mt, n := range counts {
+		types = append(types, fmt.Sprintf("%s %d", mt, n))
+		total += n
+	}
+	sort.Strings(types)
+	writeString(os.Stdout, fmt.Sprintf("messages %d: %s\n", total, strings.Join(types, ", ")))
+}
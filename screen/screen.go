@@ -8,7 +8,7 @@ import (
 
 // Screen commands
 type screenCmd interface {
-	writeOn(thisScreen *screenImpl)
+	writeOn(thisScreen *Screen)
 }
 
 // Move the cursor
@@ -36,12 +36,18 @@ type clearLine struct{}
 // Close the screen
 type closeScreen struct{}
 
-// Representation of the screen
-type screenImpl struct {
+// Signal that everything before it has been output
+type flushScreen struct {
+	done chan struct{}
+}
+
+// Screen is the terminal screen. Everything written to it is output, in order, by its own goroutine
+type Screen struct {
 	currentCursor cursorPos
 	savedCursor   cursorPos
 	ch            chan screenCmd
 	stdOut        io.Writer
+	done          chan struct{}
 }
 
 // ANSI control constants
@@ -51,15 +57,12 @@ const (
 	cursorPosition string = csi + "%d;%dH"
 	clrLine        string = csi + "2K"
 	clrScreen      string = csi + "2J"
+	reverseVideo   string = csi + "7m"
+	normalVideo    string = csi + "0m"
 
 	chanBufferSize = 5
 )
 
-// The screen
-var (
-	screen *screenImpl
-)
-
 // Command implementations
 
 // write a string to the output dealing with errors. Return the number of characters written
@@ -72,14 +75,14 @@ func safeWrite(w io.Writer, s string) int {
 }
 
 // write to the screen updating the charater position. We assume the screen does not wrap lines
-func (wm cursorPos) writeOn(thisScreen *screenImpl) {
+func (wm cursorPos) writeOn(thisScreen *Screen) {
 	// Update the cursor position
 	thisScreen.currentCursor = cursorPos{row: wm.row, col: wm.col}
 	safeWrite(thisScreen.stdOut, fmt.Sprintf(cursorPosition, wm.row, wm.col))
 }
 
 // Write a string at a given line, maintaining current cursor position
-func (wm writeScreenLine) writeOn(thisScreen *screenImpl) {
+func (wm writeScreenLine) writeOn(thisScreen *Screen) {
 	// Move and clear, write the string, then restore the current position
 	str := fmt.Sprintf(
 		cursorPosition+clrLine+"%s"+cursorPosition,
@@ -89,26 +92,30 @@ func (wm writeScreenLine) writeOn(thisScreen *screenImpl) {
 	safeWrite(thisScreen.stdOut, str)
 }
 
-func (wm writeStr) writeOn(thisScreen *screenImpl) {
+func (wm writeStr) writeOn(thisScreen *Screen) {
 	// Update the cursor column
 	thisScreen.currentCursor.col += len(wm.str)
 	safeWrite(thisScreen.stdOut, wm.str)
 }
 
-func (wm clearScreen) writeOn(thisScreen *screenImpl) {
+func (wm clearScreen) writeOn(thisScreen *Screen) {
 	thisScreen.currentCursor = cursorPos{1, 1}
 	safeWrite(thisScreen.stdOut, clrScreen)
 }
 
-func (wm clearLine) writeOn(thisScreen *screenImpl) {
+func (wm clearLine) writeOn(thisScreen *Screen) {
 	safeWrite(thisScreen.stdOut, clrLine)
 }
 
-func (wm closeScreen) writeOn(_ *screenImpl) {}
+func (wm closeScreen) writeOn(_ *Screen) {}
+
+func (wm flushScreen) writeOn(_ *Screen) {
+	close(wm.done)
+}
 
 // WriteScreenLine writes some text at a given screen line (1-based)
-func WriteScreenLine(row int, col int, s string) {
-	screen.ch <- writeScreenLine{
+func (sc *Screen) WriteScreenLine(row int, col int, s string) {
+	sc.ch <- writeScreenLine{
 		cursor: cursorPos{
 			row: row,
 			col: col,
@@ -117,40 +124,63 @@ func WriteScreenLine(row int, col int, s string) {
 	}
 }
 
+// Highlight returns s marked up to display in reverse video
+func Highlight(s string) string {
+	return reverseVideo + s + normalVideo
+}
+
 // Write a string at the current cursor position
-func Write(s string) {
-	screen.ch <- writeStr{str: s}
+func (sc *Screen) Write(s string) {
+	sc.ch <- writeStr{str: s}
 }
 
 // ClearScreen clears the screen and resets the cursor to 1,1
-func ClearScreen() {
-	screen.ch <- clearScreen{}
+func (sc *Screen) ClearScreen() {
+	sc.ch <- clearScreen{}
 }
 
 // ClearLine clears the current line
-func ClearLine() {
-	screen.ch <- clearLine{}
+func (sc *Screen) ClearLine() {
+	sc.ch <- clearLine{}
 }
 
 // PositionCursor moves the cursor to the given position (1-based)
-func PositionCursor(row, col int) {
-	screen.ch <- cursorPos{row, col}
+func (sc *Screen) PositionCursor(row, col int) {
+	sc.ch <- cursorPos{row, col}
 }
 
-// Close the screen
-func Close() {
-	screen.ch <- closeScreen{}
+// Close the screen. Everything written before the Close is output before it returns, and nothing is output after
+func (sc *Screen) Close() {
+	sc.ch <- closeScreen{}
+	<-sc.done
+}
+
+// Wait until everything written so far has been output
+func (sc *Screen) flush() {
+	done := make(chan struct{})
+	sc.ch <- flushScreen{done: done}
+	<-done
+}
+
+// Initialize creates the screen, writing to standard output, and clears the actual screen
+func Initialize() *Screen {
+	return initialize(os.Stdout)
 }
 
-// Initialize initializes the screen representation and clears the actual screen
-func Initialize() {
-	screen = &screenImpl{currentCursor: cursorPos{1, 1}, ch: make(chan screenCmd, chanBufferSize), stdOut: os.Stdout}
-	go func() {
+// Create the screen, writing to out
+func initialize(out io.Writer) *Screen {
+	sc := &Screen{currentCursor: cursorPos{1, 1}, ch: make(chan screenCmd, chanBufferSize), stdOut: out, done: make(chan struct{})}
+	go func(thisScreen *Screen) {
 		for {
-			msg := <-screen.ch
-			msg.writeOn(screen)
+			msg := <-thisScreen.ch
+			if _, ok := msg.(closeScreen); ok {
+				close(thisScreen.done)
+				return
+			}
+			msg.writeOn(thisScreen)
 		}
-	}()
+	}(sc)
 
-	ClearScreen()
+	sc.ClearScreen()
+	return sc
 }
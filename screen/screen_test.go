@@ -5,12 +5,12 @@ import (
 	"fmt"
 	"github.com/stretchr/testify/suite"
 	"testing"
-	"time"
 )
 
 type TestSuite struct {
 	suite.Suite
 	buffer *bytes.Buffer
+	screen *Screen
 }
 
 func TestScreen(t *testing.T) {
@@ -18,28 +18,27 @@ func TestScreen(t *testing.T) {
 }
 
 func (s *TestSuite) SetupTest() {
-	Initialize()
 	s.buffer = &bytes.Buffer{}
-	screen.stdOut = s.buffer
+	s.screen = initialize(s.buffer)
 }
 
 func (s *TestSuite) TearDownTest() {
-	Close()
+	s.screen.Close()
 }
 
 func (s *TestSuite) TestWrite() {
 	testString := "this string"
-	Write(testString)
-	time.Sleep(time.Millisecond)
+	s.screen.Write(testString)
+	s.screen.flush()
 	s.Assert().Equal(clrScreen+testString, s.buffer.String())
-	s.Assert().Equal(cursorPos{1, 1 + len(testString)}, screen.currentCursor)
+	s.Assert().Equal(cursorPos{1, 1 + len(testString)}, s.screen.currentCursor)
 }
 
 func (s *TestSuite) TestWriteScreenLine() {
 	testString := "this string"
-	WriteScreenLine(3, 3, testString)
-	time.Sleep(time.Millisecond)
+	s.screen.WriteScreenLine(3, 3, testString)
+	s.screen.flush()
 
 	s.Assert().Equal(fmt.Sprintf(clrScreen+cursorPosition+clrLine, 3, 3)+testString+fmt.Sprintf(cursorPosition, 1, 1), s.buffer.String())
-	s.Assert().Equal(cursorPos{1, 1}, screen.currentCursor)
+	s.Assert().Equal(cursorPos{1, 1}, s.screen.currentCursor)
 }
@@ -0,0 +1,27 @@
+package semfork
+
+import (
+	"github.com/wizardpb/diningphils-go/shared"
+)
+
+// Fork represents a fork available for eating. It has shared state, and indicates its ability to be used using a
+// semaphore - philosophers wanting the fork wait to acquire it. Since it is initialized with a single unit, any
+// philosopher acquiring it will block until the current owner releases the fork. Philosophers compete for a fork by
+// acquiring it simultaneously - the semaphore ensures that only one will (atomically) get the unit and grab the fork.
+//
+// Note that this does not ensure fairness - a Philosopher that thinks very quickly and repeatedly goes hungry can
+// repeatedly grab a fork at the expense of a slower one.
+type Fork struct {
+	shared.ForkBase
+	sem *shared.Semaphore
+}
+
+// NewFork creates the fork with the given ID for Table t. A single unit means the fork starts out free
+func NewFork(t *shared.Table, id int) *Fork {
+	return &Fork{
+		ForkBase: shared.ForkBase{
+			ID: id,
+		},
+		sem: shared.NewSemaphore(t.Clock, 1),
+	}
+}
@@ -0,0 +1,50 @@
+package semfork
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared"
+)
+
+// Philosopher is the part of a Philosopher common to every algorithm using semaphore Forks - picking forks up, putting
+// them down, and eating once both are held. Algorithms embed it in place of a bare PhilosopherBase
+type Philosopher struct {
+	*shared.PhilosopherBase
+}
+
+// Eat starts the Philosopher eating. Adds the invariant check
+func (p *Philosopher) Eat() {
+	p.CheckEating()
+	p.PhilosopherBase.Eat()
+}
+
+// PickUp picks up fork f, waiting if it's busy
+func (p *Philosopher) PickUp(f shared.Fork) {
+	f.(*Fork).sem.Acquire()
+	p.take(f)
+}
+
+// TryPickUp picks up fork f only if it is free. Returns true if we got it
+func (p *Philosopher) TryPickUp(f shared.Fork) bool {
+	if !f.(*Fork).sem.TryAcquire() {
+		return false
+	}
+	p.take(f)
+	return true
+}
+
+// PutDown puts fork f back down, and notifies any wait-er
+func (p *Philosopher) PutDown(f shared.Fork) {
+	p.Check(f.IsHeldBy(p.ID), []int{f.GetID()}, "freeing fork %d held by %d", f.GetID(), f.Holder())
+	f.SetFree()
+	p.Emit(shared.ForkPutDown, f.GetID())
+	p.WriteString(fmt.Sprintf("puts down fork %d", f.GetID()))
+	f.(*Fork).sem.Release()
+}
+
+// Mark a fork we have just acquired as ours
+func (p *Philosopher) take(f shared.Fork) {
+	p.Check(!f.IsHeld(), []int{f.GetID()}, "free fork %d shows it's owned by %d", f.GetID(), f.Holder())
+	f.SetHolder(p.ID)
+	p.Emit(shared.ForkPickedUp, f.GetID())
+	p.WriteString(fmt.Sprintf("picks up fork %d", f.GetID()))
+}
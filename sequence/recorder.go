@@ -0,0 +1,140 @@
+// Package sequence records the messages delivered to a table's philosophers, and draws them as a sequence diagram, in
+// PlantUML or Mermaid.
+package sequence
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/drinking"
+	"github.com/wizardpb/diningphils-go/shared"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Format is a sequence diagram language
+type Format int
+
+// Diagram formats
+const (
+	PlantUML Format = iota
+	Mermaid
+)
+
+var formatNames = []string{"plantuml", "mermaid"}
+
+// String implements the Stringer interface
+func (f Format) String() string {
+	return formatNames[f]
+}
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	for i, n := range formatNames {
+		if n == name {
+			return Format(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown diagram format %q - use one of %s", name, strings.Join(formatNames, ", "))
+}
+
+// Message is a message delivered to a philosopher
+type Message struct {
+	Time     time.Duration
+	From, To int
+	Text     string
+}
+
+// Window is a period of a run. A zero To means the end of the run
+type Window struct {
+	From, To time.Duration
+}
+
+// Contains returns true if time d falls in the window
+func (w Window) Contains(d time.Duration) bool {
+	return d >= w.From && (w.To == 0 || d <= w.To)
+}
+
+// Recorder records the fork and bottle messages, their request messages and state changes delivered to a Table's
+// philosophers during a Window of the run - anything outside it is dropped straight away, so a long run only keeps what
+// is drawn. Set the Table's Delivered to its Deliver method. State changes are sent by a philosopher to itself, by its
+// timers.
+type Recorder struct {
+	table    *shared.Table
+	window   Window
+	lock     sync.Mutex
+	messages []Message
+}
+
+// NewRecorder creates a recorder for Table t, recording the messages delivered in window w
+func NewRecorder(t *shared.Table, w Window) *Recorder {
+	return &Recorder{table: t, window: w}
+}
+
+// Deliver records message m, delivered to philosopher p, if it is one that is drawn and it is in the window
+func (r *Recorder) Deliver(p shared.Philosopher, m shared.Message) {
+	now := r.table.Clock.Now()
+	if !r.window.Contains(now) {
+		return
+	}
+	to := p.GetID()
+	var msg Message
+	switch mt := m.(type) {
+	case chandymisra.ForkMessage:
+		msg = Message{From: mt.Sender.GetID(), Text: fmt.Sprintf("fork %d", mt.Fork.GetID())}
+	case chandymisra.ForkRequestMessage:
+		msg = Message{From: mt.Requester.GetID(), Text: fmt.Sprintf("request fork %d", mt.Fork.GetID())}
+	case drinking.BottleMessage:
+		msg = Message{From: mt.Sender.GetID(), Text: fmt.Sprintf("bottle %d", mt.Bottle.ID)}
+	case drinking.BottleRequestMessage:
+		msg = Message{From: mt.Requester.GetID(), Text: fmt.Sprintf("request bottle %d", mt.Bottle.ID)}
+	case shared.NewState:
+		msg = Message{From: to, Text: mt.NewState.String()}
+	default:
+		return
+	}
+	msg.Time, msg.To = now, to
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.messages = append(r.messages, msg)
+}
+
+// Messages returns the messages recorded, in the order they were delivered
+func (r *Recorder) Messages() []Message {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	messages := append([]Message{}, r.messages...)
+	// Philosophers run concurrently, so messages delivered at the same moment may be recorded out of order
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].Time < messages[j].Time })
+	return messages
+}
+
+// WriteDiagram writes a sequence diagram of the messages recorded, in format f, with a participant for each philosopher
+func (r *Recorder) WriteDiagram(out io.Writer, f Format) error {
+	var b strings.Builder
+	names := r.table.Names
+	switch f {
+	case PlantUML:
+		b.WriteString("@startuml\n")
+		for i, name := range names {
+			fmt.Fprintf(&b, "participant \"%s (%d)\" as p%d\n", name, i, i)
+		}
+		for _, m := range r.Messages() {
+			fmt.Fprintf(&b, "p%d -> p%d : %v %s\n", m.From, m.To, m.Time, m.Text)
+		}
+		b.WriteString("@enduml\n")
+	case Mermaid:
+		b.WriteString("sequenceDiagram\n")
+		for i, name := range names {
+			fmt.Fprintf(&b, "    participant p%d as %s (%d)\n", i, name, i)
+		}
+		for _, m := range r.Messages() {
+			fmt.Fprintf(&b, "    p%d->>p%d: %v %s\n", m.From, m.To, m.Time, m.Text)
+		}
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
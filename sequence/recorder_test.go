@@ -0,0 +1,93 @@
+package sequence_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/drinking"
+	"github.com/wizardpb/diningphils-go/sequence"
+	"github.com/wizardpb/diningphils-go/shared"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	table, _ := shared.NewTable(3, shared.DiscardOutput{})
+	clock := shared.NewVirtualClock()
+	table.Clock = clock
+	table.Seed = 1
+	window := sequence.Window{From: 10 * time.Minute, To: 20 * time.Minute}
+	recorder := sequence.NewRecorder(table, sequence.Window{})
+	windowed := sequence.NewRecorder(table, window)
+	table.Delivered = func(p shared.Philosopher, m shared.Message) {
+		recorder.Deliver(p, m)
+		windowed.Deliver(p, m)
+	}
+	table.Seat(chandymisra.Factory)
+	table.Run(context.Background())
+	clock.Run(time.Hour)
+
+	all := recorder.Messages()
+	kinds := map[string]bool{}
+	for i, m := range all {
+		kinds[strings.Fields(m.Text)[0]] = true
+		if i > 0 {
+			assert.LessOrEqual(t, all[i-1].Time, m.Time)
+		}
+	}
+	assert.Equal(t, map[string]bool{"Hungry": true, "Thinking": true, "fork": true, "request": true}, kinds)
+
+	// Only the messages in the window are kept
+	some := windowed.Messages()
+	assert.NotEmpty(t, some)
+	assert.Less(t, len(some), len(all))
+	for _, m := range some {
+		assert.True(t, window.Contains(m.Time))
+	}
+
+	var b bytes.Buffer
+	assert.NoError(t, windowed.WriteDiagram(&b, sequence.PlantUML))
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Equal(t, "@startuml", lines[0])
+	assert.Equal(t, `participant "`+table.Names[0]+` (0)" as p0`, lines[1])
+	assert.Len(t, lines, 1+3+len(some)+1)
+	assert.Regexp(t, `^p\d -> p\d : \S+ `, lines[4])
+
+	b.Reset()
+	assert.NoError(t, windowed.WriteDiagram(&b, sequence.Mermaid))
+	lines = strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Equal(t, "sequenceDiagram", lines[0])
+	assert.Len(t, lines, 1+3+len(some))
+	assert.Regexp(t, `^    p\d->>p\d: \S+ `, lines[4])
+}
+
+func TestRecorderBottles(t *testing.T) {
+	table, _ := shared.NewTable(5, shared.DiscardOutput{})
+	clock := shared.NewVirtualClock()
+	table.Clock = clock
+	table.Seed = 1
+	recorder := sequence.NewRecorder(table, sequence.Window{})
+	table.Delivered = recorder.Deliver
+	table.Seat(drinking.Factory)
+	table.Run(context.Background())
+	clock.Run(time.Hour)
+
+	// Messages are described without the number of the fork or bottle
+	kinds := map[string]bool{}
+	for _, m := range recorder.Messages() {
+		fields := strings.Fields(m.Text)
+		kinds[strings.Join(fields[:len(fields)-1], " ")] = true
+	}
+	assert.True(t, kinds["bottle"])
+	assert.True(t, kinds["request bottle"])
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := sequence.ParseFormat("mermaid")
+	assert.NoError(t, err)
+	assert.Equal(t, sequence.Mermaid, f)
+	_, err = sequence.ParseFormat("svg")
+	assert.EqualError(t, err, `unknown diagram format "svg" - use one of plantuml, mermaid`)
+}
@@ -0,0 +1,355 @@
+package shared
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for a Table. All delays - think and eat times, retries - are scheduled through it.
+//
+// A Clock also counts outstanding work: Busy is called whenever a unit of work starts (usually a message is sent to a
+// philosopher) and Done when it finishes (the message has been executed). A real time clock ignores this, but a
+// virtual clock uses it to tell when the whole table is waiting for time to pass.
+type Clock interface {
+	// Now returns the time since the clock started
+	Now() time.Duration
+	// AfterFunc calls f in its own goroutine once d has elapsed. The call can be cancelled with the Timer returned
+	AfterFunc(d time.Duration, f func()) Timer
+	// Busy records the start of a unit of work
+	Busy()
+	// Done records the end of a unit of work
+	Done()
+}
+
+// Timer is a call scheduled by AfterFunc
+type Timer interface {
+	// Stop cancels the call. It returns false if it was too late - the call has been made, or is being made
+	Stop() bool
+}
+
+// RealClock is a Clock running in real time - or a multiple of it, set by SetSpeed. It can also be paused, freezing
+// time and holding back every pending AfterFunc call until it is resumed; while paused, Step makes the calls one at a
+// time.
+type RealClock struct {
+	lock   sync.Mutex
+	start  time.Time     // the real time when the clock last changed speed or was resumed
+	base   time.Duration // the clock time at start
+	speed  float64
+	paused bool
+	timers map[*realTimer]struct{}
+}
+
+// A call scheduled on a RealClock, due at clock time due. It is pending while it is in the clock's timers
+type realTimer struct {
+	clock *RealClock
+	due   time.Duration
+	f     func()
+	timer *time.Timer
+}
+
+// NewRealClock creates a real time clock, starting now
+func NewRealClock() *RealClock {
+	return &RealClock{start: time.Now(), speed: 1, timers: map[*realTimer]struct{}{}}
+}
+
+// Now implements the Clock interface
+func (c *RealClock) Now() time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now()
+}
+
+// The clock time. The lock must be held
+func (c *RealClock) now() time.Duration {
+	if c.paused {
+		return c.base
+	}
+	return c.base + time.Duration(float64(time.Since(c.start))*c.speed)
+}
+
+// AfterFunc implements the Clock interface
+func (c *RealClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	rt := &realTimer{clock: c, due: c.now() + d, f: f}
+	c.timers[rt] = struct{}{}
+	if !c.paused {
+		c.arm(rt)
+	}
+	return rt
+}
+
+// Busy implements the Clock interface. Real time passes regardless of work, so there is nothing to do
+func (c *RealClock) Busy() {}
+
+// Done implements the Clock interface
+func (c *RealClock) Done() {}
+
+// Speed returns how many times faster than real time the clock runs
+func (c *RealClock) Speed() float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.speed
+}
+
+// SetSpeed makes the clock run at factor times real time. Pending calls are rescheduled to match
+func (c *RealClock) SetSpeed(factor float64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.base, c.start = c.now(), time.Now()
+	c.speed = factor
+	if !c.paused {
+		c.armAll()
+	}
+}
+
+// Paused returns true if the clock is paused
+func (c *RealClock) Paused() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.paused
+}
+
+// Pause stops the clock. Time stands still, and no AfterFunc calls are made until it is resumed
+func (c *RealClock) Pause() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.paused {
+		return
+	}
+	c.base = c.now()
+	c.paused = true
+	for rt := range c.timers {
+		if rt.timer != nil {
+			rt.timer.Stop()
+		}
+	}
+}
+
+// Resume restarts a paused clock
+func (c *RealClock) Resume() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.paused {
+		return
+	}
+	c.start = time.Now()
+	c.paused = false
+	c.armAll()
+}
+
+// Step moves a paused clock on to the next pending AfterFunc call, and makes it. It returns false if the clock is
+// running, or nothing is pending
+func (c *RealClock) Step() bool {
+	c.lock.Lock()
+	var next *realTimer
+	for rt := range c.timers {
+		if next == nil || rt.due < next.due {
+			next = rt
+		}
+	}
+	if !c.paused || next == nil {
+		c.lock.Unlock()
+		return false
+	}
+	delete(c.timers, next)
+	if next.due > c.base {
+		c.base = next.due
+	}
+	c.lock.Unlock()
+
+	go next.f()
+	return true
+}
+
+// Start the real timer for a pending call. The lock must be held
+func (c *RealClock) arm(rt *realTimer) {
+	delay := time.Duration(float64(rt.due-c.now()) / c.speed)
+	rt.timer = time.AfterFunc(delay, rt.fire)
+}
+
+// Restart the real timers for every pending call. The lock must be held
+func (c *RealClock) armAll() {
+	for rt := range c.timers {
+		if rt.timer != nil {
+			rt.timer.Stop()
+		}
+		c.arm(rt)
+	}
+}
+
+// Make the call, if it is still pending and the clock is running. A timer that fires just as the clock is paused, or
+// just after the call has been rescheduled or made by Step, finds it has nothing to do
+func (rt *realTimer) fire() {
+	c := rt.clock
+	c.lock.Lock()
+	_, pending := c.timers[rt]
+	if !pending || c.paused {
+		c.lock.Unlock()
+		return
+	}
+	if rt.due > c.now() {
+		// Rounding in the speed calculation - go round again for the rest
+		c.arm(rt)
+		c.lock.Unlock()
+		return
+	}
+	delete(c.timers, rt)
+	c.lock.Unlock()
+	rt.f()
+}
+
+// Stop implements the Timer interface
+func (rt *realTimer) Stop() bool {
+	c := rt.clock
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, pending := c.timers[rt]
+	delete(c.timers, rt)
+	if rt.timer != nil {
+		rt.timer.Stop()
+	}
+	return pending
+}
+
+// A pending event on a VirtualClock. seq breaks ties between events due at the same time, so they fire in the order
+// they were scheduled
+type event struct {
+	clock   *VirtualClock
+	at      time.Duration
+	seq     uint64
+	f       func()
+	fired   bool
+	stopped bool
+}
+
+// Stop implements the Timer interface. A stopped event stays in the queue, and is discarded when it reaches the front
+func (e *event) Stop() bool {
+	e.clock.lock.Lock()
+	defer e.clock.lock.Unlock()
+	if e.fired || e.stopped {
+		return false
+	}
+	e.stopped = true
+	return true
+}
+
+// eventHeap is a priority queue of events, earliest first
+type eventHeap []*event
+
+func (h eventHeap) Len() int { return len(h) }
+func (h eventHeap) Less(i, j int) bool {
+	return h[i].at < h[j].at || (h[i].at == h[j].at && h[i].seq < h[j].seq)
+}
+func (h eventHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *eventHeap) Push(x interface{}) { *h = append(*h, x.(*event)) }
+func (h *eventHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// VirtualClock is a discrete event scheduler. Time only moves when Run is called, and then it jumps straight to the
+// next pending event as soon as there is no work in progress - so a table spends no real time at all thinking or
+// eating, and hours of dining can be simulated in seconds.
+//
+// Events are fired one at a time, and each one is allowed to run to completion (including any messages it causes to
+// be sent) before the next. Blocking waits inside Execute must go through a Semaphore so that the waiter is not
+// counted as busy.
+type VirtualClock struct {
+	lock   sync.Mutex
+	idle   *sync.Cond
+	now    time.Duration
+	busy   int
+	seq    uint64
+	events eventHeap
+	paused bool
+}
+
+// NewVirtualClock creates a virtual clock, stopped at time zero
+func NewVirtualClock() *VirtualClock {
+	c := &VirtualClock{}
+	c.idle = sync.NewCond(&c.lock)
+	return c
+}
+
+// Now implements the Clock interface
+func (c *VirtualClock) Now() time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// AfterFunc implements the Clock interface
+func (c *VirtualClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.seq++
+	e := &event{clock: c, at: c.now + d, seq: c.seq, f: f}
+	heap.Push(&c.events, e)
+	return e
+}
+
+// Busy implements the Clock interface
+func (c *VirtualClock) Busy() {
+	c.lock.Lock()
+	c.busy++
+	c.lock.Unlock()
+}
+
+// Done implements the Clock interface
+func (c *VirtualClock) Done() {
+	c.lock.Lock()
+	c.busy--
+	if c.busy < 0 {
+		panic("virtual clock work count is negative")
+	}
+	if c.busy == 0 {
+		c.idle.Broadcast()
+	}
+	c.lock.Unlock()
+}
+
+// Pause stops Run, once the work in progress is done
+func (c *VirtualClock) Pause() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.paused = true
+}
+
+// Run advances virtual time, firing events in order, until the next event is later than until. It returns the time
+// reached, which is earlier than until only if the clock was paused, or the table stalled - nothing was left to do
+// and nothing was scheduled, which means every philosopher is blocked for good.
+func (c *VirtualClock) Run(until time.Duration) time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for {
+		for c.busy > 0 {
+			c.idle.Wait()
+		}
+		if c.paused {
+			return c.now
+		}
+		for len(c.events) > 0 && c.events[0].stopped {
+			heap.Pop(&c.events)
+		}
+		if len(c.events) == 0 {
+			return c.now
+		}
+		if c.events[0].at > until {
+			c.now = until
+			return c.now
+		}
+
+		e := heap.Pop(&c.events).(*event)
+		e.fired = true
+		c.now = e.at
+		c.busy++
+		go func() {
+			e.f()
+			c.Done()
+		}()
+	}
+}
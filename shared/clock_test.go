@@ -0,0 +1,93 @@
+package shared_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/shared"
+	"testing"
+	"time"
+)
+
+func TestVirtualClockOrder(t *testing.T) {
+	c := shared.NewVirtualClock()
+	fired := make(chan time.Duration, 3)
+	for _, d := range []time.Duration{3 * time.Hour, time.Hour, 2 * time.Hour} {
+		c.AfterFunc(d, func() { fired <- c.Now() })
+	}
+
+	// Once the last event has fired there is nothing left to do, so time stops there
+	start := time.Now()
+	assert.Equal(t, 3*time.Hour, c.Run(10*time.Hour))
+	assert.Less(t, time.Since(start), time.Second)
+
+	close(fired)
+	order := []time.Duration{}
+	for d := range fired {
+		order = append(order, d)
+	}
+	assert.Equal(t, []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour}, order)
+}
+
+func TestVirtualClockUntil(t *testing.T) {
+	// Time stops at until, leaving later events pending
+	c := shared.NewVirtualClock()
+	c.AfterFunc(2*time.Hour, func() {})
+	assert.Equal(t, time.Hour, c.Run(time.Hour))
+	assert.Equal(t, time.Hour, c.Now())
+}
+
+func TestVirtualTables(t *testing.T) {
+	for name, f := range factories {
+		table, err := shared.NewTable(5, shared.DiscardOutput{})
+		assert.NoError(t, err)
+		clock := shared.NewVirtualClock()
+		stats := shared.NewStats(table.Names)
+		table.Clock = clock
+		table.Sinks = append(table.Sinks, stats)
+		table.Seed = 1
+		table.Seat(f)
+		table.Run(context.Background())
+		assert.Equal(t, 10*time.Hour, clock.Run(10*time.Hour), "%s stalled", name)
+
+		// Thinking and eating take 10s on average, so each philosopher gets a few hundred meals
+		for _, p := range stats.Report().Philosophers {
+			assert.Greater(t, p.Meals, 100, "%s: %s is starving", name, p.Name)
+		}
+	}
+}
+
+func TestVirtualClockStop(t *testing.T) {
+	c := shared.NewVirtualClock()
+	fired := false
+	timer := c.AfterFunc(time.Hour, func() { fired = true })
+	assert.True(t, timer.Stop())
+	assert.False(t, timer.Stop())
+	assert.Equal(t, time.Duration(0), c.Run(2*time.Hour), "a stopped event shouldn't keep the clock going")
+	assert.False(t, fired)
+}
+
+func TestRealClockControl(t *testing.T) {
+	c := shared.NewRealClock()
+	fired := make(chan time.Duration, 2)
+	c.AfterFunc(time.Hour, func() { fired <- c.Now() })
+	c.AfterFunc(2*time.Hour, func() { fired <- c.Now() })
+
+	// Paused, time stands still, and steps go straight to the next call
+	c.Pause()
+	now := c.Now()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, now, c.Now())
+	assert.True(t, c.Step())
+	assert.InDelta(t, time.Hour, <-fired, float64(time.Second))
+
+	// Fast enough, the second call comes at once
+	c.SetSpeed(1e6)
+	c.Resume()
+	select {
+	case at := <-fired:
+		assert.GreaterOrEqual(t, at, 2*time.Hour)
+	case <-time.After(time.Second):
+		assert.Fail(t, "sped up call wasn't made")
+	}
+	assert.False(t, c.Step(), "nothing to step while running")
+}
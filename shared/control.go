@@ -0,0 +1,103 @@
+package shared
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+)
+
+// Control is a Message for a philosopher's run loop rather than its algorithm. It changes how the philosopher runs,
+// and is applied in the philosopher's own goroutine, between calls to Execute, so it needs no locking.
+type Control interface {
+	Message
+	Apply(p Philosopher)
+}
+
+// based is implemented by every Philosopher built on a PhilosopherBase
+type based interface {
+	Base() *PhilosopherBase
+}
+
+// Apply c to p if it is built on a PhilosopherBase - controls only work through the base
+func applyControl(c Control, p Philosopher) {
+	if _, ok := p.(based); ok {
+		c.Apply(p)
+	}
+}
+
+// SetThinkRange changes how long a philosopher thinks for, from the next time it starts thinking
+type SetThinkRange struct {
+	Range TimeRange
+}
+
+// String implements the Stringer interface
+func (m SetThinkRange) String() string {
+	return fmt.Sprintf("Set think range: %d-%d", m.Range.Min, m.Range.Max)
+}
+
+// Apply implements the Control interface
+func (m SetThinkRange) Apply(p Philosopher) {
+	p.(based).Base().ThinkRange = m.Range
+}
+
+// SetEatRange changes how long a philosopher eats for, from the next time it starts eating
+type SetEatRange struct {
+	Range TimeRange
+}
+
+// String implements the Stringer interface
+func (m SetEatRange) String() string {
+	return fmt.Sprintf("Set eat range: %d-%d", m.Range.Min, m.Range.Max)
+}
+
+// Apply implements the Control interface
+func (m SetEatRange) Apply(p Philosopher) {
+	p.(based).Base().EatRange = m.Range
+}
+
+// MakeHungry makes a thinking philosopher hungry straight away, as if it had finished thinking. It does nothing if the
+// philosopher isn't thinking, or is just about to get hungry anyway - and then calls Ignored, if set, with the reason.
+// Ignored is called from the philosopher's goroutine
+type MakeHungry struct {
+	Ignored func(reason string)
+}
+
+// String implements the Stringer interface
+func (m MakeHungry) String() string {
+	return "Make hungry"
+}
+
+// Apply implements the Control interface. Cancel the end of thinking, then do it now
+func (m MakeHungry) Apply(p Philosopher) {
+	pb := p.(based).Base()
+	reason := ""
+	switch {
+	case pb.State != philstate.Thinking:
+		reason = fmt.Sprintf("it is %s, not Thinking", pb.State)
+	case pb.timer == nil || !pb.timer.Stop():
+		reason = "it is just about to get hungry anyway"
+	default:
+		p.Execute(NewState{NewState: philstate.Hungry})
+		return
+	}
+	if m.Ignored != nil {
+		m.Ignored(reason)
+	}
+}
+
+// stopMessage is sent to every philosopher when the table starts shutting down. A thinking philosopher stops
+// straight away, unless it is just about to get hungry anyway; anyone else stops when they next start thinking
+type stopMessage struct{}
+
+// String implements the Stringer interface
+func (m stopMessage) String() string {
+	return "Stop"
+}
+
+// Apply implements the Control interface. Cancel the end of thinking, and stop instead
+func (m stopMessage) Apply(p Philosopher) {
+	pb := p.(based).Base()
+	if pb.State != philstate.Thinking || pb.timer == nil || !pb.timer.Stop() {
+		return
+	}
+	pb.stop()
+}
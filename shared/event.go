@@ -0,0 +1,69 @@
+package shared
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"time"
+)
+
+// EventType identifies what happened in an Event
+type EventType string
+
+// Event types
+const (
+	StateChanged        EventType = "state"                 // The philosopher changed state
+	ForkPickedUp        EventType = "fork_pickup"           // The philosopher picked up a fork
+	ForkPutDown         EventType = "fork_putdown"          // The philosopher put a fork down
+	ForkSent            EventType = "fork_sent"             // The philosopher sent a fork to Peer
+	ForkReceived        EventType = "fork_received"         // The philosopher received a fork from Peer
+	ForkRequested       EventType = "fork_requested"        // The philosopher sent a request for a fork to Peer
+	ForkRequestReceived EventType = "fork_request_received" // The philosopher received a request for a fork from Peer
+	Starving            EventType = "starving"              // The philosopher has been hungry too long
+	InvariantViolated   EventType = "violation"             // The philosopher found a broken invariant, given in Detail
+
+	// The drinking philosophers also pass bottles
+	BottleSent            EventType = "bottle_sent"             // The philosopher sent a bottle to Peer
+	BottleReceived        EventType = "bottle_received"         // The philosopher received a bottle from Peer
+	BottleRequested       EventType = "bottle_requested"        // The philosopher sent a request for a bottle to Peer
+	BottleRequestReceived EventType = "bottle_request_received" // The philosopher received a request for a bottle from Peer
+)
+
+// NoPeer is the Peer of an Event that doesn't involve another philosopher
+const NoPeer = -1
+
+// Event records something that happened at a Table. Events are emitted as they happen, and passed to the Table's
+// EventSinks to be traced, counted or checked.
+type Event struct {
+	Time        time.Duration  `json:"time"`              // When it happened, by the Table's Clock
+	Philosopher int            `json:"philosopher"`       // Who it happened to
+	Type        EventType      `json:"type"`              // What happened
+	State       philstate.Enum `json:"state"`             // The philosopher's state afterwards
+	Forks       []int          `json:"forks,omitempty"`   // The forks involved - for a state change, the forks held
+	Bottles     []int          `json:"bottles,omitempty"` // The bottles involved
+	Peer        int            `json:"peer"`              // The other philosopher involved, or NoPeer
+	Detail      string         `json:"detail,omitempty"`  // A description, for events that need one
+}
+
+// String implements the Stringer interface
+func (e Event) String() string {
+	s := fmt.Sprintf("%v philosopher %d (%s) %s", e.Time, e.Philosopher, e.State, e.Type)
+	if len(e.Forks) > 0 {
+		s += fmt.Sprintf(" forks %v", e.Forks)
+	}
+	if len(e.Bottles) > 0 {
+		s += fmt.Sprintf(" bottles %v", e.Bottles)
+	}
+	if e.Peer != NoPeer {
+		s += fmt.Sprintf(" peer %d", e.Peer)
+	}
+	if e.Detail != "" {
+		s += ": " + e.Detail
+	}
+	return s
+}
+
+// EventSink receives Events from a Table. Events are emitted by every philosopher's goroutine, so sinks must be
+// safe for concurrent use
+type EventSink interface {
+	Record(e Event)
+}
@@ -3,6 +3,7 @@ package shared
 // Fork is an interface used to control and set fork state. It is implemented by each different algorithm
 type Fork interface {
 	GetID() int
+	Holder() int
 	IsHeld() bool
 	IsHeldBy(id int) bool
 	SetHolder(id int)
@@ -1,35 +1,42 @@
 package shared
 
-const UnOwned = 0 // Default value ensures Forks always initialize free
+import "sync/atomic"
 
-// ForkBase is a common element of all implementation Forks
+const UnOwned = -1 // The Holder of a free Fork
+
+// ForkBase is a common element of all implementation Forks. A fork is shared by two philosophers, each running in its
+// own goroutine, so the holder is kept atomically
 type ForkBase struct {
-	ID     int // The fork ID
-	Holder int // Who holds the fork - UnOwned if free
+	ID     int          // The fork ID
+	holder atomic.Int32 // Who holds the fork, plus one - so the zero value means the fork starts out free
 }
 
-// GetIS returns the Fork ID
+// GetID returns the Fork ID
 func (f *ForkBase) GetID() int {
 	return f.ID
 }
 
+// Holder returns the ID of the Philosopher holding the fork, or UnOwned if it is free
+func (f *ForkBase) Holder() int {
+	return int(f.holder.Load()) - 1
+}
+
 // IsHeld indicates that the fork is held by someone
 func (f *ForkBase) IsHeld() bool {
-	return f.Holder != UnOwned
+	return f.Holder() != UnOwned
 }
 
 // IsHeldBy indicates that the fork is held by Philosopher id
 func (f *ForkBase) IsHeldBy(id int) bool {
-	// Philosophers index from 0, so increment to avoid the UnOwned value
-	return f.Holder == id+1
+	return f.Holder() == id
 }
 
 // SetHolder sets the Fork owner
 func (f *ForkBase) SetHolder(id int) {
-	f.Holder = id + 1
+	f.holder.Store(int32(id + 1))
 }
 
 // SetFree marks the fork as free
 func (f *ForkBase) SetFree() {
-	f.Holder = UnOwned
+	f.holder.Store(0)
 }
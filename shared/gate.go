@@ -0,0 +1,86 @@
+package shared
+
+import "sync"
+
+// Gate controls the delivery of messages to a Table's philosophers - and to anything else at the table that executes
+// messages, such as the waiter. While it is open, messages are executed as soon as they arrive. While it is closed,
+// each message received is held at the gate - freezing the whole table, whatever channels the algorithm uses - until
+// Step lets the oldest one through, or the gate opens.
+type Gate struct {
+	// Executed is called, if set, once a message let through by Step has been executed by r
+	Executed func(r Receiver, m Message)
+
+	lock    sync.Mutex
+	closed  bool
+	waiting []*heldMessage
+}
+
+// A message held at a closed Gate. release is sent true when it is stepped through, false when the gate opens
+type heldMessage struct {
+	release chan bool
+}
+
+// Close closes the gate
+func (g *Gate) Close() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.closed = true
+}
+
+// Open opens the gate, letting every held message through
+func (g *Gate) Open() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.closed = false
+	for _, h := range g.waiting {
+		h.release <- false
+	}
+	g.waiting = nil
+}
+
+// Closed returns true if the gate is closed
+func (g *Gate) Closed() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.closed
+}
+
+// Step lets the oldest held message through. It returns false if no messages are held
+func (g *Gate) Step() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if len(g.waiting) == 0 {
+		return false
+	}
+	g.waiting[0].release <- true
+	g.waiting = g.waiting[1:]
+	return true
+}
+
+// Pass executes message m, received by r, by calling execute once the gate lets it through. Every philosopher's run
+// loop passes its messages through the gate, and so must any other Receiver with a loop of its own
+func (g *Gate) Pass(c Clock, r Receiver, m Message, execute func()) {
+	stepped := g.wait(c)
+	execute()
+	if stepped && g.Executed != nil {
+		g.Executed(r, m)
+	}
+}
+
+// Wait while the gate is closed, returning true if the message was let through by Step. A held message is not work
+// in progress for Clock c, just as a philosopher waiting for a Semaphore isn't
+func (g *Gate) wait(c Clock) bool {
+	g.lock.Lock()
+	if !g.closed {
+		g.lock.Unlock()
+		return false
+	}
+	h := &heldMessage{release: make(chan bool, 1)}
+	g.waiting = append(g.waiting, h)
+	g.lock.Unlock()
+
+	c.Done()
+	stepped := <-h.release
+	c.Busy()
+	return stepped
+}
@@ -0,0 +1,40 @@
+package shared_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/shared"
+	"testing"
+	"time"
+)
+
+func TestGateStep(t *testing.T) {
+	table, _ := shared.NewTable(2, shared.DiscardOutput{})
+	table.ThinkRange = shared.TimeRange{Min: 1, Max: 5, Unit: time.Millisecond}
+	table.EatRange = shared.TimeRange{Min: 1, Max: 5, Unit: time.Millisecond}
+	clock := table.Clock.(*shared.RealClock)
+	executed := make(chan string, 10)
+	table.Gate.Executed = func(r shared.Receiver, m shared.Message) { executed <- m.String() }
+
+	// Freeze the table before it starts. Stepping the clock makes someone hungry, but that is only executed when
+	// the gate is stepped
+	clock.Pause()
+	table.Gate.Close()
+	table.Seat(factories["fingers"])
+	ctx, stop := context.WithCancel(context.Background())
+	table.Run(ctx)
+	assert.False(t, table.Gate.Step())
+	// Philosophers start in their own goroutines, so wait for someone to start thinking
+	assert.Eventually(t, clock.Step, time.Second, time.Millisecond)
+	assert.Eventually(t, table.Gate.Step, time.Second, time.Millisecond)
+	assert.Equal(t, "NewState: Hungry", <-executed)
+
+	// Fingers eat straight away, so nothing more happens until the clock moves again
+	time.Sleep(10 * time.Millisecond)
+	assert.False(t, table.Gate.Step())
+
+	table.Gate.Open()
+	clock.Resume()
+	assert.False(t, table.Gate.Closed())
+	shutdown(t, stop, table)
+}
@@ -1,43 +1,80 @@
 package shared
 
 import (
+	"bufio"
 	"fmt"
 	"github.com/wizardpb/diningphils-go/screen"
+	"os"
+	"strings"
 )
 
-// Control constants for timings, number of Philosophers,etc.
+// Control constants for timings, screen layout, etc.
 const (
-	NPhils   int = 5
-	ThinkMin     = 5
-	ThinkMax     = 15
-	EatMin       = 5
-	EatMax       = 15
+	ThinkMin = 5
+	ThinkMax = 15
+	EatMin   = 5
+	EatMax   = 15
 
-	ScreenPos  = 3
-	PromptLine = ScreenPos + NPhils + 3
+	DefaultNPhils = 5
+	MinNPhils     = 2
+
+	ScreenPos = 3
 
 	promptString = "> "
 )
 
-// Philosophers are numbers 0 to NPhils-1, as are forks.
-//
-// The fork to the left of Philosopher[i] is Fork[i]; the fork to the right is Fork[i+1 mod NPhils], since
-// the wrap around the table
-var (
-	PhilNames    [NPhils]string = [NPhils]string{"Hannah Arendt", "Judith Butler", "Patricia Churchland", "Simone de Beauvoir", "Themistoclea"}
-	Philosophers [NPhils]Philosopher
-	Forks        [NPhils]Fork
-)
+// The names of the first philosophers at a table. Any more than this get generated names
+var philNames = []string{"Hannah Arendt", "Judith Butler", "Patricia Churchland", "Simone de Beauvoir", "Themistoclea"}
+
+// PhilosopherNames returns the names of the philosophers at a table of n
+func PhilosopherNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		if i < len(philNames) {
+			names[i] = philNames[i]
+		} else {
+			names[i] = fmt.Sprintf("Philosopher %d", i)
+		}
+	}
+	return names
+}
+
+// StatsLine is the screen line for the live statistics, just below the lines of a table of nPhils philosophers
+func StatsLine(nPhils int) int {
+	return ScreenPos + nPhils + 1
+}
 
-// ReadCmd reads and executes a command string from the terminal
-func ReadCmd() string {
-	screen.PositionCursor(PromptLine, 1)
-	screen.ClearLine()
-	screen.Write(promptString)
-	var cmd string
-	_, err := fmt.Scanln(&cmd)
+// NoticeLine is the screen line for notices, such as the message just executed when single stepping, between the
+// statistics and the prompt
+func NoticeLine(nPhils int) int {
+	return ScreenPos + nPhils + 2
+}
+
+// PromptLine is the screen line for the command prompt, below the lines of a table of nPhils philosophers
+func PromptLine(nPhils int) int {
+	return ScreenPos + nPhils + 3
+}
+
+// InfoLine is the first screen line for the output of commands, just below the prompt line
+func InfoLine(nPhils int) int {
+	return PromptLine(nPhils) + 2
+}
+
+// Where commands are read from
+var stdin = bufio.NewReader(os.Stdin)
+
+// ReadCmd reads a command line from the terminal, prompting on the given line of screen sc. Any message - such as the
+// error from the last command - is shown before the prompt
+func ReadCmd(sc *screen.Screen, line int, message string) string {
+	sc.PositionCursor(line, 1)
+	sc.ClearLine()
+	if message != "" {
+		sc.Write(message + " ")
+	}
+	sc.Write(promptString)
+	cmd, err := stdin.ReadString('\n')
 	if err != nil {
 		panic("screen read error")
 	}
-	return cmd
+	return strings.TrimSpace(cmd)
 }
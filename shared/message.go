@@ -12,6 +12,11 @@ type Message interface {
 	fmt.Stringer
 }
 
+// Receiver is anything that receives Messages - every Philosopher is one
+type Receiver interface {
+	Messages() chan Message
+}
+
 // NewState is a message whic cuases a Philosoper to change state (e.g, Thinking to Hungry)
 type NewState struct {
 	NewState philstate.Enum
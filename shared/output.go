@@ -0,0 +1,68 @@
+package shared
+
+import (
+	"github.com/wizardpb/diningphils-go/screen"
+	"sync"
+)
+
+// Output is where a Table's philosophers report what they are doing. Each philosopher writes to its own line,
+// numbered by its ID
+type Output interface {
+	WriteLine(line int, s string)
+}
+
+// ScreenOutput writes philosopher lines to Screen, starting at screen row Row
+type ScreenOutput struct {
+	Screen *screen.Screen
+	Row    int
+}
+
+// WriteLine implements the Output interface
+func (o ScreenOutput) WriteLine(line int, s string) {
+	o.Screen.WriteScreenLine(o.Row+line, 1, s)
+}
+
+// DiscardOutput throws everything away
+type DiscardOutput struct{}
+
+// WriteLine implements the Output interface
+func (o DiscardOutput) WriteLine(int, string) {}
+
+// HighlightOutput wraps a screen Output, remembering the last thing written to each line so that a line can be redrawn
+// highlighted to draw attention to it. Lines stay highlighted, through any further writes, until turned off
+type HighlightOutput struct {
+	Output
+	lock   sync.Mutex
+	lines  map[int]string
+	marked map[int]bool
+}
+
+// NewHighlightOutput creates a HighlightOutput writing to out
+func NewHighlightOutput(out Output) *HighlightOutput {
+	return &HighlightOutput{Output: out, lines: map[int]string{}, marked: map[int]bool{}}
+}
+
+// WriteLine implements the Output interface
+func (o *HighlightOutput) WriteLine(line int, s string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.lines[line] = s
+	o.write(line)
+}
+
+// Highlight turns highlighting of a line on or off, and redraws it
+func (o *HighlightOutput) Highlight(line int, on bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.marked[line] = on
+	o.write(line)
+}
+
+// Write a line as it should currently appear. The lock must be held
+func (o *HighlightOutput) write(line int) {
+	s := o.lines[line]
+	if o.marked[line] {
+		s = screen.Highlight(s)
+	}
+	o.Output.WriteLine(line, s)
+}
@@ -18,18 +18,40 @@ type CreateParams struct {
 	Name       string
 	ThinkRange TimeRange
 	EatRange   TimeRange
+	Rand       Rand
 }
 
-// Factory is a factory function type for creating Forks and Philosophers
-type Factory func(params CreateParams) (Philosopher, Fork)
+// Factory is a factory function type for creating Forks and Philosophers at Table t
+type Factory func(t *Table, params CreateParams) (Philosopher, Fork)
 
-// Run sets up the core run loop - repeatedly receive and execute Messages, then Start. The Start initializes the
-// initial state and then sets the Philosopher thinking (generally by calling the base Start() method).
-func Run(p Philosopher) {
+// Run starts the Philosopher's goroutine, which calls Start and then runs the core loop - repeatedly receive and
+// execute Messages, until the Table closes the channel when it shuts down. The Start initializes the initial state and
+// then sets the Philosopher thinking (generally by calling the base Start() method). Starting in the same goroutine as
+// the messages are executed means a Philosopher's own state is never touched by any other goroutine.
+//
+// Control messages are applied by the loop itself. Every message must pass the Table's Gate before it is executed. Each
+// message was counted as work for the Table's Clock when it was sent, and is done once it has been executed - and so
+// is the Start. A panic is passed to the Table's Panicked, if it is set.
+func Run(t *Table, p Philosopher) {
+	t.loops.Add(1)
+	t.Clock.Busy()
 	go func() {
+		defer t.loops.Done()
+		defer t.Recover()
+		p.Start()
+		t.Clock.Done()
 		for m := range p.Messages() {
-			p.Execute(m)
+			t.Gate.Pass(t.Clock, p, m, func() {
+				if t.Delivered != nil {
+					t.Delivered(p, m)
+				}
+				if c, ok := m.(Control); ok {
+					applyControl(c, p)
+				} else {
+					p.Execute(m)
+				}
+			})
+			t.Clock.Done()
 		}
 	}()
-	p.Start()
 }
@@ -2,26 +2,52 @@ package shared
 
 import (
 	"fmt"
-	"github.com/wizardpb/diningphils-go/screen"
 	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"sync/atomic"
 )
 
-// PhilosopherBase implements features commons to all algorithm implementations
+// PhilosopherBase implements features commons to all algorithm implementations.
+//
+// Everything here belongs to the Philosopher's own goroutine - the one running Start and executing its messages. The
+// exception is the state, which neighbors, monitors and the screen all need to see: State is for the Philosopher's own
+// use, and SetState publishes it atomically for everyone else to read with GetState.
 type PhilosopherBase struct {
+	Table       *Table
 	ID          int
 	Name        string
 	State       philstate.Enum
 	ThinkRange  TimeRange
 	EatRange    TimeRange
+	Rand        Rand
 	MessageChan chan Message
+	timer       Timer        // the last DelaySend
+	published   atomic.Int32 // State, as seen by other goroutines
 }
 
 // StartThinking - philosopher is thinking, arrange for them to go hungry
 func (pb *PhilosopherBase) StartThinking() {
 	pb.WriteString("starts thinking")
+	pb.ScheduleHunger()
+}
+
+// ScheduleHunger arranges for a thinking philosopher to get hungry once it has thought for a while - unless the table
+// is shutting down, when it stops instead
+func (pb *PhilosopherBase) ScheduleHunger() {
+	if pb.Table.Stopping() {
+		pb.stop()
+		return
+	}
 	pb.DelaySend(pb.ThinkRange, NewState{NewState: philstate.Hungry})
 }
 
+// Stop the philosopher. It keeps on executing messages - a neighbor may still need a fork it holds - until the whole
+// table has stopped
+func (pb *PhilosopherBase) stop() {
+	pb.SetState(philstate.Stopped)
+	pb.WriteString("has stopped")
+	pb.Table.philosopherStopped()
+}
+
 // StartEating - set the philosopher eating, arrange for them to finish and think
 func (pb *PhilosopherBase) StartEating() {
 	pb.WriteString("starts eating")
@@ -31,28 +57,65 @@ func (pb *PhilosopherBase) StartEating() {
 // Eat - set the Philosopher in the Eat state
 func (pb *PhilosopherBase) Eat() {
 
-	pb.State = philstate.Eating
+	pb.SetState(philstate.Eating)
 	pb.StartEating()
 }
 
+// SetState changes the Philosopher's state, and emits a StateChanged event
+func (pb *PhilosopherBase) SetState(s philstate.Enum) {
+	pb.State = s
+	pb.published.Store(int32(s))
+	pb.Emit(StateChanged, pb.heldForks()...)
+}
+
+// Emit sends an event of type et about this Philosopher, involving the given forks, to the Table's sinks
+func (pb *PhilosopherBase) Emit(et EventType, forks ...int) {
+	pb.emit(Event{Type: et, Forks: forks, Peer: NoPeer})
+}
+
+// EmitPeer is Emit for an event that also involves another Philosopher
+func (pb *PhilosopherBase) EmitPeer(et EventType, peer int, forks ...int) {
+	pb.emit(Event{Type: et, Forks: forks, Peer: peer})
+}
+
+// EmitBottle is EmitPeer for an event involving a drinking philosopher's bottle, rather than forks
+func (pb *PhilosopherBase) EmitBottle(et EventType, peer int, bottle int) {
+	pb.emit(Event{Type: et, Bottles: []int{bottle}, Peer: peer})
+}
+
+// Fill in who and what state, and pass the event to the Table
+func (pb *PhilosopherBase) emit(e Event) {
+	if len(pb.Table.Sinks) == 0 {
+		return
+	}
+	e.Philosopher = pb.ID
+	e.State = pb.State
+	pb.Table.Emit(e)
+}
+
+// Check reports an invariant violation to the Table if ok is false. forks are the forks involved, and the message is
+// formatted from format and args. It returns ok
+func (pb *PhilosopherBase) Check(ok bool, forks []int, format string, args ...interface{}) bool {
+	if !ok {
+		pb.Table.Violate(pb.ID, forks, fmt.Sprintf(format, args...))
+	}
+	return ok
+}
+
 // CheckEating checks two invariants that should be true when a Philosopher eats.
 // Implement this separately because the 'fingers' implementation intentionally violates this
 func (pb *PhilosopherBase) CheckEating() {
 	// Check the primary invariant - neither neighbor should be eating, and I should hold
 	// both forks
-	Assert(
-		func() bool {
-			return pb.LeftPhilosopher().GetState() != philstate.Eating &&
-				pb.RightPhilosopher().GetState() != philstate.Eating
-		},
+	pb.Check(
+		pb.LeftPhilosopher().GetState() != philstate.Eating && pb.RightPhilosopher().GetState() != philstate.Eating,
+		nil,
 		"eat while a neighbor is eating",
 	)
 
-	Assert(
-		func() bool {
-			return pb.LeftFork().IsHeldBy(pb.ID) &&
-				pb.RightFork().IsHeldBy(pb.ID)
-		},
+	pb.Check(
+		pb.LeftFork().IsHeldBy(pb.ID) && pb.RightFork().IsHeldBy(pb.ID),
+		[]int{pb.leftForkID(), pb.rightForkID()},
 		"eat without holding forks",
 	)
 }
@@ -67,14 +130,19 @@ func (pb *PhilosopherBase) IsEating() bool {
 	return pb.State == philstate.Eating
 }
 
+// Base returns the PhilosopherBase itself, giving Control messages access to it whatever it is embedded in
+func (pb *PhilosopherBase) Base() *PhilosopherBase {
+	return pb
+}
+
 // GetID returns the philosopher ID
 func (pb *PhilosopherBase) GetID() int {
 	return pb.ID
 }
 
-// GetState returns the current Philosopher state
+// GetState returns the current Philosopher state. It is safe to call from any goroutine
 func (pb *PhilosopherBase) GetState() philstate.Enum {
-	return pb.State
+	return philstate.Enum(pb.published.Load())
 }
 
 // Messages returms the message channel
@@ -84,17 +152,21 @@ func (pb *PhilosopherBase) Messages() chan Message {
 
 // Start sets the philosopher thinking
 func (pb *PhilosopherBase) Start() {
-	pb.State = philstate.Thinking
+	pb.SetState(philstate.Thinking)
 	pb.StartThinking()
 }
 
 // Runnable returns true if the Philosopher is runnable
 func (pb *PhilosopherBase) Runnable() bool {
-	return pb.State != philstate.Stopped
+	return pb.GetState() != philstate.Stopped
 }
 
-// WriteString writes a string to the screen on the line dedicated to the philosopher
+// WriteString writes a string to the table output on the line dedicated to the philosopher
 func (pb *PhilosopherBase) WriteString(s string) {
+	if _, discard := pb.Table.Output.(DiscardOutput); discard {
+		// Don't waste time formatting - this matters when simulating in virtual time
+		return
+	}
 	forkState := ""
 	switch {
 	case pb.HoldsFork(pb.LeftFork()) && pb.HoldsFork(pb.RightFork()):
@@ -105,12 +177,29 @@ func (pb *PhilosopherBase) WriteString(s string) {
 		forkState = fmt.Sprintf(", holds fork %d", pb.rightForkID())
 	}
 
-	screen.WriteScreenLine(ScreenPos+pb.ID, 1, fmt.Sprintf("%s (%d,%s) %s%s", pb.Name, pb.ID, pb.State, s, forkState))
+	pb.Table.Output.WriteLine(pb.ID, fmt.Sprintf("%s (%d,%s) %s%s", pb.Name, pb.ID, pb.State, s, forkState))
 }
 
 // DelaySend sends the given messages to the Philosopher after a random wait given by t
 func (pb *PhilosopherBase) DelaySend(t TimeRange, m Message) {
-	SendIn(RandDuration(t), m, pb)
+	pb.timer = SendIn(RandDuration(pb.Rand, t), m, pb)
+}
+
+// Send sends a Message to another Philosopher (or anything else that receives messages) at the table
+func (pb *PhilosopherBase) Send(to Receiver, m Message) {
+	pb.Table.Send(to, m)
+}
+
+// The IDs of the left and right forks, if I hold them
+func (pb *PhilosopherBase) heldForks() []int {
+	held := []int{}
+	if pb.HoldsFork(pb.LeftFork()) {
+		held = append(held, pb.leftForkID())
+	}
+	if pb.HoldsFork(pb.RightFork()) {
+		held = append(held, pb.rightForkID())
+	}
+	return held
 }
 
 // left fork ID is always the same as the Philosopher ID
@@ -120,18 +209,18 @@ func (pb *PhilosopherBase) leftForkID() int {
 
 // right fork ID is always the same as the Philosopher ID + 1, wrapping around the table
 func (pb *PhilosopherBase) rightForkID() int {
-	return (pb.ID + 1) % NPhils
+	return (pb.ID + 1) % pb.Table.NPhils
 }
 
 // LeftFork returns the fork on the Philosophers left - the one at its ID.
 func (pb *PhilosopherBase) LeftFork() Fork {
-	return Forks[pb.leftForkID()]
+	return pb.Table.Forks[pb.leftForkID()]
 }
 
 // RightFork returns the fork on the Philosophers right - the one at its ID + 1,
 // wrapping around the table.
 func (pb *PhilosopherBase) RightFork() Fork {
-	return Forks[pb.rightForkID()]
+	return pb.Table.Forks[pb.rightForkID()]
 }
 
 // IsLeftFork returns true if the passed in fork is to the left of this Philosopher
@@ -152,12 +241,12 @@ func (pb *PhilosopherBase) HoldsFork(f Fork) bool {
 // LeftPhilosopher returns the Philosopher to the left of me
 func (pb *PhilosopherBase) LeftPhilosopher() Philosopher {
 	// Add NPhils to avoid a negative index
-	index := (pb.ID + NPhils - 1) % NPhils
-	return Philosophers[index]
+	index := (pb.ID + pb.Table.NPhils - 1) % pb.Table.NPhils
+	return pb.Table.Philosophers[index]
 }
 
 // RightPhilosopher returns the Philosopher to the right of me
 func (pb *PhilosopherBase) RightPhilosopher() Philosopher {
-	index := (pb.ID + 1) % NPhils
-	return Philosophers[index]
+	index := (pb.ID + 1) % pb.Table.NPhils
+	return pb.Table.Philosophers[index]
 }
@@ -1,5 +1,7 @@
 package philstate
 
+import "fmt"
+
 // Enum is an integer state symbol
 type Enum int
 
@@ -28,3 +30,19 @@ func (e Enum) String() string {
 	}
 	return s
 }
+
+// MarshalText implements encoding.TextMarshaler, so states appear by name in JSON
+func (e Enum) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (e *Enum) UnmarshalText(text []byte) error {
+	for v, s := range vals {
+		if s == string(text) {
+			*e = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown philosopher state %q", text)
+}
@@ -0,0 +1,61 @@
+package shared
+
+import "sync"
+
+// Semaphore is a counting semaphore. Acquire takes a unit, waiting for one to be released if none are free, and
+// Release gives one back. Waiters are served in the order they arrived, just like receivers on a channel.
+//
+// It works with the Table's Clock: a philosopher waiting in Acquire is not busy, and becomes busy again only when a
+// Release hands it a unit. This lets a virtual clock move time on while philosophers are waiting for forks.
+type Semaphore struct {
+	clock   Clock
+	lock    sync.Mutex
+	free    int
+	waiters []chan struct{}
+}
+
+// NewSemaphore creates a Semaphore using Clock c, with n free units
+func NewSemaphore(c Clock, n int) *Semaphore {
+	return &Semaphore{clock: c, free: n}
+}
+
+// Acquire takes a unit, waiting if none are free. It must only be called by busy work, e.g. from Execute
+func (s *Semaphore) Acquire() {
+	s.lock.Lock()
+	if s.free > 0 {
+		s.free--
+		s.lock.Unlock()
+		return
+	}
+	wake := make(chan struct{})
+	s.waiters = append(s.waiters, wake)
+	// We stop being busy while we wait. Release will make us busy again as it hands us the unit
+	s.clock.Done()
+	s.lock.Unlock()
+	<-wake
+}
+
+// TryAcquire takes a unit only if one is free, and returns true if it did
+func (s *Semaphore) TryAcquire() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.free > 0 {
+		s.free--
+		return true
+	}
+	return false
+}
+
+// Release gives a unit back, handing it directly to the longest waiter if there is one
+func (s *Semaphore) Release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.waiters) == 0 {
+		s.free++
+		return
+	}
+	wake := s.waiters[0]
+	s.waiters = s.waiters[1:]
+	s.clock.Busy()
+	close(wake)
+}
@@ -0,0 +1,269 @@
+package shared
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"io"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stats is an EventSink that measures how well a Table feeds its philosophers: for each philosopher the number of
+// meals, the total time spent eating, and how long they had to wait to eat once hungry - and the most philosophers
+// eating at once. It is safe for concurrent use, so it can be queried while the table is running.
+type Stats struct {
+	lock      sync.Mutex
+	names     []string
+	phils     []philStats
+	eating    int // how many philosophers are eating now
+	maxEating int
+}
+
+// The running statistics for one philosopher
+type philStats struct {
+	state  philstate.Enum
+	since  time.Duration // when the philosopher entered state
+	meals  int
+	eating time.Duration
+	waits  waitHistogram
+}
+
+// The number of sub-buckets each power of two is split into, as a power of two - 16 sub-buckets, so a percentile is
+// accurate to within 1/16th
+const subBucketBits = 4
+
+// waitHistogram keeps hunger waits in constant space, however long the table runs: the count, total, min and max
+// exactly, and a log-linear histogram to estimate the 99th percentile from
+type waitHistogram struct {
+	count    int
+	total    time.Duration
+	min, max time.Duration
+	buckets  [(64 - subBucketBits) << subBucketBits]int
+}
+
+// The bucket holding d. Values below 2^subBucketBits get a bucket each; above that each power of two is split into
+// 2^subBucketBits buckets
+func bucketOf(d time.Duration) int {
+	v := uint64(d)
+	if v < 1<<subBucketBits {
+		return int(v)
+	}
+	shift := bits.Len64(v) - subBucketBits - 1
+	return (shift+1)<<subBucketBits + int(v>>shift) - 1<<subBucketBits
+}
+
+// The largest value in bucket i
+func bucketMax(i int) time.Duration {
+	if i < 1<<subBucketBits {
+		return time.Duration(i)
+	}
+	shift := i>>subBucketBits - 1
+	low := uint64(1<<subBucketBits+i&(1<<subBucketBits-1)) << shift
+	return time.Duration(low + 1<<shift - 1)
+}
+
+// Add a wait
+func (h *waitHistogram) add(d time.Duration) {
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.total += d
+	h.buckets[bucketOf(d)]++
+}
+
+// Add all the waits in o
+func (h *waitHistogram) merge(o *waitHistogram) {
+	if o.count == 0 {
+		return
+	}
+	if h.count == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	if o.max > h.max {
+		h.max = o.max
+	}
+	h.count += o.count
+	h.total += o.total
+	for i, n := range o.buckets {
+		h.buckets[i] += n
+	}
+}
+
+// Summarize the waits. The 99th percentile is the top of the bucket holding it, but never more than the max
+func (h *waitHistogram) stats() WaitStats {
+	if h.count == 0 {
+		return WaitStats{}
+	}
+	s := WaitStats{Count: h.count, Min: h.min, Avg: h.total / time.Duration(h.count), Max: h.max, P99: h.max}
+	// Nearest rank percentile
+	rank, seen := (h.count*99+99)/100, 0
+	for i, n := range h.buckets {
+		seen += n
+		if seen >= rank {
+			if b := bucketMax(i); b < h.max {
+				s.P99 = b
+			}
+			break
+		}
+	}
+	return s
+}
+
+// WaitStats summarizes a set of hunger waits - the time from becoming hungry to starting to eat
+type WaitStats struct {
+	Count int           `json:"count"`
+	Min   time.Duration `json:"min"`
+	Avg   time.Duration `json:"avg"`
+	Max   time.Duration `json:"max"`
+	P99   time.Duration `json:"p99"`
+}
+
+// PhilosopherReport is the statistics for a single philosopher
+type PhilosopherReport struct {
+	Name   string        `json:"name"`
+	Meals  int           `json:"meals"`
+	Eating time.Duration `json:"eating"`
+	Wait   WaitStats     `json:"wait"`
+}
+
+// StatsReport is a snapshot of the statistics for the whole Table.
+//
+// The fairness figures are Jain's fairness index, (Σx)² / (n·Σx²), over the philosophers' meal counts and eating times.
+// They range from 1/n, when one philosopher gets everything, to 1 when everyone gets exactly the same.
+type StatsReport struct {
+	Philosophers   []PhilosopherReport `json:"philosophers"`
+	Meals          int                 `json:"meals"`
+	Eating         time.Duration       `json:"eating"`
+	Wait           WaitStats           `json:"wait"`
+	MealFairness   float64             `json:"meal_fairness"`
+	EatingFairness float64             `json:"eating_fairness"`
+	MaxEating      int                 `json:"max_eating"` // The most philosophers eating at once
+}
+
+// NewStats creates an empty Stats for a table of philosophers with the given names
+func NewStats(names []string) *Stats {
+	return &Stats{names: names, phils: make([]philStats, len(names))}
+}
+
+// Record implements the EventSink interface. Only state changes matter
+func (s *Stats) Record(e Event) {
+	if e.Type != StateChanged {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ps := &s.phils[e.Philosopher]
+	switch {
+	case ps.state == philstate.Eating && e.State != philstate.Eating:
+		ps.eating += e.Time - ps.since
+		s.eating--
+	case ps.state != philstate.Eating && e.State == philstate.Eating:
+		if ps.state == philstate.Hungry {
+			ps.meals++
+			ps.waits.add(e.Time - ps.since)
+		}
+		s.eating++
+		if s.eating > s.maxEating {
+			s.maxEating = s.eating
+		}
+	}
+	ps.state = e.State
+	ps.since = e.Time
+}
+
+// Report takes a snapshot of the statistics
+func (s *Stats) Report() StatsReport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r := StatsReport{}
+	allWaits := &waitHistogram{}
+	meals := []float64{}
+	eating := []float64{}
+	for i := range s.phils {
+		ps := &s.phils[i]
+		r.Philosophers = append(r.Philosophers, PhilosopherReport{
+			Name:   s.names[i],
+			Meals:  ps.meals,
+			Eating: ps.eating,
+			Wait:   ps.waits.stats(),
+		})
+		r.Meals += ps.meals
+		r.Eating += ps.eating
+		allWaits.merge(&ps.waits)
+		meals = append(meals, float64(ps.meals))
+		eating = append(eating, float64(ps.eating))
+	}
+	r.Wait = allWaits.stats()
+	r.MealFairness = JainIndex(meals)
+	r.EatingFairness = JainIndex(eating)
+	r.MaxEating = s.maxEating
+	return r
+}
+
+// Line is a one line summary of the statistics so far, for display while the table runs
+func (s *Stats) Line() string {
+	s.lock.Lock()
+	meals, worst := 0, time.Duration(0)
+	total := time.Duration(0)
+	counts := []float64{}
+	for i := range s.phils {
+		ps := &s.phils[i]
+		meals += ps.meals
+		counts = append(counts, float64(ps.meals))
+		total += ps.waits.total
+		if ps.waits.max > worst {
+			worst = ps.waits.max
+		}
+	}
+	s.lock.Unlock()
+
+	avg := time.Duration(0)
+	if meals > 0 {
+		avg = total / time.Duration(meals)
+	}
+	return fmt.Sprintf("meals %d, hunger wait avg %v max %v, fairness %.3f", meals, roundDuration(avg), roundDuration(worst), JainIndex(counts))
+}
+
+// JainIndex is Jain's fairness index of xs. An empty or all zero set is perfectly fair
+func JainIndex(xs []float64) float64 {
+	sum, sumSq := 0.0, 0.0
+	for _, x := range xs {
+		sum += x
+		sumSq += x * x
+	}
+	if sumSq == 0 {
+		return 1
+	}
+	return sum * sum / (float64(len(xs)) * sumSq)
+}
+
+// Round a duration for display
+func roundDuration(d time.Duration) time.Duration {
+	return d.Round(10 * time.Millisecond)
+}
+
+// WriteTo writes the report as a table
+func (r StatsReport) WriteTo(w io.Writer) (int64, error) {
+	b := &strings.Builder{}
+	row := "%-20s %8v %12v %10v %10v %10v %10v\n"
+	fmt.Fprintf(b, row, "Philosopher", "Meals", "Eating", "Wait min", "avg", "max", "p99")
+	for _, p := range r.Philosophers {
+		fmt.Fprintf(b, row, p.Name, p.Meals, roundDuration(p.Eating),
+			roundDuration(p.Wait.Min), roundDuration(p.Wait.Avg), roundDuration(p.Wait.Max), roundDuration(p.Wait.P99))
+	}
+	fmt.Fprintf(b, row, "Total", r.Meals, roundDuration(r.Eating),
+		roundDuration(r.Wait.Min), roundDuration(r.Wait.Avg), roundDuration(r.Wait.Max), roundDuration(r.Wait.P99))
+	fmt.Fprintf(b, "Fairness (Jain's index): meals %.4f, eating time %.4f\n", r.MealFairness, r.EatingFairness)
+	fmt.Fprintf(b, "Most eating at once: %d\n", r.MaxEating)
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
@@ -0,0 +1,67 @@
+package shared_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"testing"
+	"time"
+)
+
+func TestJainIndex(t *testing.T) {
+	assert.Equal(t, 1.0, shared.JainIndex([]float64{3, 3, 3}))
+	assert.Equal(t, 0.25, shared.JainIndex([]float64{8, 0, 0, 0}))
+	assert.Equal(t, 1.0, shared.JainIndex([]float64{0, 0}))
+}
+
+func TestStats(t *testing.T) {
+	stats := shared.NewStats([]string{"A", "B"})
+	state := func(at time.Duration, id int, s philstate.Enum) {
+		stats.Record(shared.Event{Time: at * time.Second, Philosopher: id, Type: shared.StateChanged, State: s})
+	}
+
+	// A eats twice, waiting 1s and 3s; B eats once, waiting 100s
+	state(0, 0, philstate.Thinking)
+	state(1, 0, philstate.Hungry)
+	state(2, 0, philstate.Eating)
+	state(7, 0, philstate.Thinking)
+	state(8, 0, philstate.Hungry)
+	state(11, 0, philstate.Eating)
+	state(12, 0, philstate.Thinking)
+	state(0, 1, philstate.Hungry)
+	state(100, 1, philstate.Eating)
+	// Other events are ignored
+	stats.Record(shared.Event{Time: 101 * time.Second, Philosopher: 1, Type: shared.ForkPutDown})
+
+	r := stats.Report()
+	assert.Equal(t, 3, r.Meals)
+	assert.Equal(t, 2, r.Philosophers[0].Meals)
+	assert.Equal(t, 6*time.Second, r.Philosophers[0].Eating)
+	assert.Equal(t, shared.WaitStats{Count: 2, Min: time.Second, Avg: 2 * time.Second, Max: 3 * time.Second, P99: 3 * time.Second}, r.Philosophers[0].Wait)
+	// B is still eating, so hasn't clocked up any eating time yet
+	assert.Equal(t, time.Duration(0), r.Philosophers[1].Eating)
+	assert.Equal(t, 100*time.Second, r.Wait.Max)
+	assert.InDelta(t, 0.9, r.MealFairness, 1e-9)
+	assert.Equal(t, 0.5, r.EatingFairness)
+	// A and B never ate at the same time
+	assert.Equal(t, 1, r.MaxEating)
+}
+
+func TestStatsPercentile(t *testing.T) {
+	stats := shared.NewStats([]string{"A"})
+	for i := 1; i <= 1000; i++ {
+		at := time.Duration(i) * time.Hour
+		stats.Record(shared.Event{Time: at, Type: shared.StateChanged, State: philstate.Hungry})
+		stats.Record(shared.Event{Time: at + time.Duration(i)*time.Millisecond, Type: shared.StateChanged,
+			State: philstate.Eating})
+	}
+
+	// The waits are 1ms to 1s, so the 99th percentile is 990ms - or a little over, from the histogram
+	w := stats.Report().Wait
+	assert.Equal(t, 1000, w.Count)
+	assert.Equal(t, time.Millisecond, w.Min)
+	assert.Equal(t, 500500*time.Microsecond, w.Avg)
+	assert.Equal(t, time.Second, w.Max)
+	assert.GreaterOrEqual(t, w.P99, 990*time.Millisecond)
+	assert.LessOrEqual(t, w.P99, 990*time.Millisecond*17/16)
+}
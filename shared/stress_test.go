@@ -0,0 +1,85 @@
+package shared_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/console"
+	"github.com/wizardpb/diningphils-go/monitor"
+	"github.com/wizardpb/diningphils-go/shared"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestStress runs every algorithm at once, in real time, with everything that watches or controls a table - monitors,
+// a trace, snapshots and console commands - all busy at the same time, and then shuts them all down. It is most
+// useful run with the race detector:
+//
+//	go test -race -run TestStress ./shared
+func TestStress(t *testing.T) {
+	var wg sync.WaitGroup
+	for name, f := range factories {
+		wg.Add(1)
+		go func(name string, f shared.Factory) {
+			defer wg.Done()
+			stress(t, name, f)
+		}(name, f)
+	}
+	wg.Wait()
+}
+
+func stress(t *testing.T, name string, f shared.Factory) {
+	table, _ := shared.NewTable(5, &mealCounter{})
+	table.ThinkRange = shared.TimeRange{Min: 1, Max: 5, Unit: time.Millisecond}
+	table.EatRange = shared.TimeRange{Min: 1, Max: 5, Unit: time.Millisecond}
+	table.Policy = shared.LogViolation
+
+	stats := shared.NewStats(table.Names)
+	trace := shared.NewTraceSink(io.Discard, shared.NewTraceHeader(table, name))
+	detector := monitor.NewDeadlockDetector(table, nil)
+	defer detector.Stop()
+	watchdog := monitor.NewStarvationWatchdog(table)
+	watchdog.MaxWait = 10 * time.Millisecond
+	table.Sinks = append(table.Sinks, stats, trace, detector, watchdog)
+	// The drinking philosophers don't sit in a ring, so they can't be checked for safety
+	safety := monitor.NewSafetyMonitor(table)
+	if name != "drinking" {
+		table.Sinks = append(table.Sinks, safety)
+	}
+	table.Seat(f)
+	ctx, stop := context.WithCancel(context.Background())
+	table.Run(ctx)
+
+	// Poke the table from outside while it runs
+	c := &console.Console{Table: table, Stats: stats}
+	commands := []string{"pause", "step", "step", "resume", "speed 2", "think 1 0 1", "eat 2 0 1", "hungry 3",
+		"stats", "speed 1"}
+	deadline := time.Now().Add(300 * time.Millisecond)
+	for i := 0; time.Now().Before(deadline); i++ {
+		// Stepping fails if nothing is due yet, which is fine
+		cmd := commands[i%len(commands)]
+		if err := c.Execute(cmd); cmd != "step" {
+			assert.NoError(t, err, name)
+		}
+		table.Snapshot()
+		stats.Line()
+		time.Sleep(time.Millisecond)
+	}
+
+	table.Gate.Open()
+	table.Clock.(*shared.RealClock).Resume()
+	stop()
+	wait, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, table.Wait(wait), name)
+	assert.NoError(t, trace.Close(), name)
+	assert.Empty(t, table.Violations(), name)
+	assert.Empty(t, detector.Found(), name)
+	assert.Positive(t, table.Output.(*mealCounter).count(), name)
+	if name != "fingers" {
+		v, found := safety.Found()
+		assert.False(t, found, fmt.Sprintf("%s: %s", name, v))
+	}
+}
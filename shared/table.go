@@ -0,0 +1,245 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Table is a single dining table - its philosophers and forks, where they report what they are doing, how long they
+// spend thinking and eating, the clock that measures it, the gate that can freeze it, and where the events of the run
+// are sent. Everything a simulation needs is reached through its Table, so any number of independent tables, running
+// the same or different algorithms, can share a process.
+//
+// Philosophers are numbered 0 to NPhils-1, as are forks. The fork to the left of Philosophers[i] is Forks[i]; the fork
+// to the right is Forks[i+1 mod NPhils], since they wrap around the table.
+type Table struct {
+	NPhils       int
+	Names        []string
+	Philosophers []Philosopher
+	Forks        []Fork
+	Output       Output
+	Clock        Clock
+	Gate         *Gate
+	Sinks        []EventSink
+	ThinkRange   TimeRange
+	EatRange     TimeRange
+
+	// Seed is the root of all the randomness at the table. Every philosopher draws from its own stream derived from
+	// it, so a philosopher's sequence of think and eat times can be replayed by running again with the same seed.
+	// NewRand creates the streams - it defaults to the deterministic math/rand PRNG, but any source can be plugged in.
+	Seed    int64
+	NewRand func(seed int64) Rand
+	// Rand is the table's own stream, used by algorithms for any random choices made while setting up the table
+	Rand Rand
+
+	// Policy says what happens when an invariant is violated, and Violated, if set, is called first with each one
+	Policy   ViolationPolicy
+	Violated func(v Violation)
+
+	// Panicked, if set, is called with the value of any panic on a goroutine running for the table - such as under
+	// PanicOnViolation - instead of the program crashing there. The program can then put the terminal back before
+	// panicking itself. It is called from the goroutine that panicked, which stops
+	Panicked func(v interface{})
+
+	// Delivered, if set, is called with each message taken from a philosopher's channel, just before it is executed.
+	// It is called from the philosopher's goroutine
+	Delivered func(p Philosopher, m Message)
+
+	messagesLock sync.Mutex
+	messages     map[reflect.Type]int // how many of each type of message have been sent
+
+	valuesLock sync.Mutex
+	values     map[interface{}]interface{}
+
+	violationsLock sync.Mutex
+	violations     []Violation
+	halted         chan struct{}
+	haltOnce       sync.Once
+
+	ctx     context.Context
+	running sync.WaitGroup // philosophers that haven't stopped yet
+	loops   sync.WaitGroup // run loops that haven't exited yet
+	stopped chan struct{}  // closed once every philosopher has stopped
+}
+
+// NewTable creates an empty table for n philosophers, writing to out, with the default timings
+func NewTable(n int, out Output) (*Table, error) {
+	if n < MinNPhils {
+		return nil, fmt.Errorf("need at least %d philosophers, got %d", MinNPhils, n)
+	}
+	t := &Table{
+		NPhils:       n,
+		Names:        PhilosopherNames(n),
+		Philosophers: make([]Philosopher, n),
+		Forks:        make([]Fork, n),
+		Output:       out,
+		Clock:        NewRealClock(),
+		Gate:         &Gate{},
+		ThinkRange:   TimeRange{Min: ThinkMin, Max: ThinkMax, Unit: time.Second},
+		EatRange:     TimeRange{Min: EatMin, Max: EatMax, Unit: time.Second},
+		Seed:         time.Now().UnixNano(),
+		NewRand:      NewRand,
+		messages:     map[reflect.Type]int{},
+		values:       map[interface{}]interface{}{},
+		ctx:          context.Background(),
+		stopped:      make(chan struct{}),
+		halted:       make(chan struct{}),
+	}
+	return t, nil
+}
+
+// The random stream number used for the table's own Rand
+const tableStream = -1
+
+// RandFor returns a new random source for the given stream (usually a philosopher ID), derived from the table's Seed.
+// Streams are independent, so the numbers drawn from one don't depend on how much anyone else has drawn
+func (t *Table) RandFor(stream int) Rand {
+	return t.NewRand(streamSeed(t.Seed, stream))
+}
+
+// Seat creates the philosophers and forks for the table using the Factory f
+func (t *Table) Seat(f Factory) {
+	t.Rand = t.RandFor(tableStream)
+	for i, name := range t.Names {
+		params := CreateParams{
+			ID:         i,
+			Name:       name,
+			ThinkRange: t.ThinkRange,
+			EatRange:   t.EatRange,
+			Rand:       t.RandFor(i),
+		}
+		t.Philosophers[i], t.Forks[i] = f(t, params)
+	}
+}
+
+// Run starts every philosopher at the table. Cancelling ctx shuts the table down in an orderly way:
+//
+//   - nobody gets hungry any more - philosophers that are thinking stop straight away, and their timers are cancelled
+//   - philosophers that are hungry or eating carry on until they have eaten and put their forks down, then stop
+//   - once everyone has stopped, every run loop exits
+//
+// Wait waits for all of this to finish.
+func (t *Table) Run(ctx context.Context) {
+	t.ctx = ctx
+	t.running.Add(t.NPhils)
+	for _, p := range t.Philosophers {
+		Run(t, p)
+	}
+	if ctx.Done() != nil {
+		go t.shutdown()
+	}
+}
+
+// Wait waits until the table has shut down after the context passed to Run is cancelled, or until ctx is done. If ctx
+// is done first it returns an error naming the philosophers who didn't stop - they may be deadlocked.
+func (t *Table) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		t.loops.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	running := []string{}
+	for i, p := range t.Philosophers {
+		if p.Runnable() {
+			running = append(running, fmt.Sprintf("%s (%d, %s)", t.Names[i], i, p.GetState()))
+		}
+	}
+	return fmt.Errorf("gave up waiting for %s to stop", strings.Join(running, ", "))
+}
+
+// Stopping returns true once the table has started shutting down. A table that hasn't been Run - such as one whose
+// philosophers are driven step by step by a model checker - never stops
+func (t *Table) Stopping() bool {
+	return t.ctx != nil && t.ctx.Err() != nil
+}
+
+// Stopped returns a channel that is closed once every philosopher has stopped. Anything else running for the table,
+// such as the waiter, should then exit
+func (t *Table) Stopped() <-chan struct{} {
+	return t.stopped
+}
+
+// Shut the table down once the context is cancelled. Every philosopher is told, so that those that are thinking can
+// stop; then, once everyone has stopped, the message channels are closed so that the run loops exit. Nobody sends any
+// more messages by then: only hungry philosophers ask for forks, and only hungry philosophers are waited on.
+func (t *Table) shutdown() {
+	<-t.ctx.Done()
+	var told sync.WaitGroup
+	for _, p := range t.Philosophers {
+		told.Add(1)
+		go func(p Philosopher) {
+			t.Send(p, stopMessage{})
+			told.Done()
+		}(p)
+	}
+	told.Wait()
+	t.running.Wait()
+	close(t.stopped)
+	for _, p := range t.Philosophers {
+		close(p.Messages())
+	}
+}
+
+// Record that a philosopher has stopped
+func (t *Table) philosopherStopped() {
+	t.running.Done()
+}
+
+// Emit timestamps Event e and passes it to every EventSink
+func (t *Table) Emit(e Event) {
+	if len(t.Sinks) == 0 {
+		return
+	}
+	e.Time = t.Clock.Now()
+	for _, s := range t.Sinks {
+		s.Record(e)
+	}
+}
+
+// Send sends Message m to r (usually a Philosopher). All messages to philosophers must be sent this way, so that the
+// Clock can count them as work in progress until they have been executed
+func (t *Table) Send(r Receiver, m Message) {
+	if _, ok := m.(Control); !ok {
+		t.messagesLock.Lock()
+		t.messages[reflect.TypeOf(m)]++
+		t.messagesLock.Unlock()
+	}
+	t.Clock.Busy()
+	r.Messages() <- m
+}
+
+// MessageCounts returns how many of each type of message have been sent so far, by type name - such as
+// "chandymisra.ForkMessage". Control messages, which steer the run rather than being part of it, aren't counted
+func (t *Table) MessageCounts() map[string]int {
+	t.messagesLock.Lock()
+	defer t.messagesLock.Unlock()
+	counts := map[string]int{}
+	for mt, n := range t.messages {
+		counts[mt.String()] = n
+	}
+	return counts
+}
+
+// Value returns the table-wide value stored under key, calling create to make it the first time it is asked for.
+// Algorithms use this for state shared by all of their philosophers at a table, such as the waiter. As with context
+// values, keys should be of an unexported type to avoid collisions between packages
+func (t *Table) Value(key interface{}, create func() interface{}) interface{} {
+	t.valuesLock.Lock()
+	defer t.valuesLock.Unlock()
+	v, ok := t.values[key]
+	if !ok {
+		v = create()
+		t.values[key] = v
+	}
+	return v
+}
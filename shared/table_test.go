@@ -0,0 +1,161 @@
+package shared_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/chandymisra"
+	"github.com/wizardpb/diningphils-go/drinking"
+	"github.com/wizardpb/diningphils-go/fingers"
+	"github.com/wizardpb/diningphils-go/footman"
+	"github.com/wizardpb/diningphils-go/lehmannrabin"
+	"github.com/wizardpb/diningphils-go/resourcehierarchy"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"github.com/wizardpb/diningphils-go/waiter"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mealCounter is an Output that counts the meals (or drinks) reported at a table
+type mealCounter struct {
+	lock  sync.Mutex
+	meals int
+}
+
+func (o *mealCounter) WriteLine(_ int, s string) {
+	if strings.Contains(s, "starts eating") || strings.Contains(s, "starts drinking") {
+		o.lock.Lock()
+		o.meals++
+		o.lock.Unlock()
+	}
+}
+
+func (o *mealCounter) count() int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.meals
+}
+
+// Every implementation
+var factories = map[string]shared.Factory{
+	"fingers":           fingers.Factory,
+	"resourcehierarchy": resourcehierarchy.Factory,
+	"chandymisra":       chandymisra.Factory,
+	"waiter":            waiter.Factory,
+	"footman":           footman.Factory,
+	"lehmannrabin":      lehmannrabin.Factory,
+	"drinking":          drinking.Factory,
+}
+
+func TestNewTable(t *testing.T) {
+	table, err := shared.NewTable(7, shared.DiscardOutput{})
+	assert.NoError(t, err)
+	assert.Len(t, table.Philosophers, 7)
+	assert.Len(t, table.Forks, 7)
+	assert.Equal(t, "Hannah Arendt", table.Names[0])
+	assert.Equal(t, "Philosopher 6", table.Names[6])
+
+	_, err = shared.NewTable(1, shared.DiscardOutput{})
+	assert.Error(t, err)
+}
+
+func TestRandFor(t *testing.T) {
+	r := shared.TimeRange{Min: 0, Max: 1000, Unit: time.Millisecond}
+	draw := func(seed int64, stream int) []time.Duration {
+		table, _ := shared.NewTable(3, shared.DiscardOutput{})
+		table.Seed = seed
+		rnd := table.RandFor(stream)
+		ds := []time.Duration{}
+		for i := 0; i < 10; i++ {
+			ds = append(ds, shared.RandDuration(rnd, r))
+		}
+		return ds
+	}
+
+	assert.Equal(t, draw(42, 1), draw(42, 1))
+	assert.NotEqual(t, draw(42, 1), draw(42, 2))
+	assert.NotEqual(t, draw(42, 1), draw(43, 1))
+}
+
+// shutdown stops tables running in real time, and waits for every philosopher to finish
+func shutdown(t *testing.T, stop context.CancelFunc, tables ...*shared.Table) {
+	stop()
+	wait, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, table := range tables {
+		assert.NoError(t, table.Wait(wait))
+	}
+}
+
+func TestTablesSideBySide(t *testing.T) {
+	// Two tables for each algorithm, all running at once
+	ctx, stop := context.WithCancel(context.Background())
+	tables := []*shared.Table{}
+	counters := map[string][]*mealCounter{}
+	for name, f := range factories {
+		for i := 0; i < 2; i++ {
+			out := &mealCounter{}
+			table, err := shared.NewTable(3+i, out)
+			assert.NoError(t, err)
+			table.ThinkRange = shared.TimeRange{Min: 1, Max: 5, Unit: time.Millisecond}
+			table.EatRange = shared.TimeRange{Min: 1, Max: 5, Unit: time.Millisecond}
+			table.Seat(f)
+			table.Run(ctx)
+			tables = append(tables, table)
+			counters[name] = append(counters[name], out)
+		}
+	}
+
+	time.Sleep(time.Second)
+
+	for name, outs := range counters {
+		for i, out := range outs {
+			assert.Positive(t, out.count(), "%s table %d made no progress", name, i)
+		}
+	}
+	shutdown(t, stop, tables...)
+}
+
+func TestShutdown(t *testing.T) {
+	for name, f := range factories {
+		table, _ := shared.NewTable(5, shared.DiscardOutput{})
+		table.ThinkRange = shared.TimeRange{Min: 1, Max: 5, Unit: time.Millisecond}
+		table.EatRange = shared.TimeRange{Min: 1, Max: 5, Unit: time.Millisecond}
+		table.Seat(f)
+		ctx, stop := context.WithCancel(context.Background())
+		table.Run(ctx)
+		time.Sleep(100 * time.Millisecond)
+
+		stop()
+		wait, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		assert.NoError(t, table.Wait(wait), name)
+		cancel()
+		for _, p := range table.Philosophers {
+			assert.Equal(t, philstate.Stopped, p.GetState(), name)
+		}
+		select {
+		case <-table.Stopped():
+		default:
+			assert.Fail(t, "table not stopped", name)
+		}
+	}
+}
+
+func TestMessageCounts(t *testing.T) {
+	table, _ := shared.NewTable(3, shared.DiscardOutput{})
+	clock := shared.NewVirtualClock()
+	table.Clock = clock
+	table.Seed = 1
+	table.Seat(chandymisra.Factory)
+	table.Run(context.Background())
+	clock.Run(time.Hour)
+
+	counts := table.MessageCounts()
+	assert.Positive(t, counts["shared.NewState"])
+	assert.Positive(t, counts["chandymisra.ForkRequestMessage"])
+	// Every fork sent was asked for, but the last requests may not have been answered yet
+	assert.LessOrEqual(t, counts["chandymisra.ForkMessage"], counts["chandymisra.ForkRequestMessage"])
+	assert.Len(t, counts, 3)
+}
@@ -1,8 +1,7 @@
 package shared
 
 import (
-	"crypto/rand"
-	"math/big"
+	"math/rand"
 	"time"
 )
 
@@ -12,17 +11,44 @@ type TimeRange struct {
 	Unit     time.Duration
 }
 
+// Rand is a source of random numbers. *rand.Rand implements it. Each philosopher has its own, only ever used from its
+// own goroutine, so it need not be safe for concurrent use
+type Rand interface {
+	Int63n(n int64) int64
+}
+
+// NewRand is the default random source constructor - the deterministic math/rand PRNG
+func NewRand(seed int64) Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
 // RandDuration returns a random duration between the given TimeRange
-func RandDuration(r TimeRange) time.Duration {
-	delta := big.NewInt(int64(r.Max - r.Min))
-	t, _ := rand.Int(rand.Reader, delta)
-	return time.Duration(int64(r.Min)+t.Int64()) * r.Unit
+func RandDuration(rnd Rand, r TimeRange) time.Duration {
+	t := int64(0)
+	if r.Max > r.Min {
+		t = rnd.Int63n(int64(r.Max - r.Min))
+	}
+	return time.Duration(int64(r.Min)+t) * r.Unit
+}
+
+// RandBool returns the result of a fair coin toss
+func RandBool(rnd Rand) bool {
+	return rnd.Int63n(2) == 1
+}
+
+// streamSeed derives the seed for an independent random stream from a table seed, by scrambling the two together
+// with the SplitMix64 finalizer - so that nearby seeds, or nearby streams, don't give related sequences
+func streamSeed(seed int64, stream int) int64 {
+	z := uint64(seed) + uint64(stream+1)*0x9E3779B97F4A7C15
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return int64(z ^ (z >> 31))
 }
 
-// SendIn sends the Message m to the Philosopher pb after delay Duration
-func SendIn(delay time.Duration, m Message, pb *PhilosopherBase) {
-	go func() {
-		time.Sleep(delay)
-		pb.MessageChan <- m
-	}()
+// SendIn sends the Message m to the Philosopher pb after delay Duration, as measured by the Table's Clock. The send can
+// be cancelled by stopping the Timer returned
+func SendIn(delay time.Duration, m Message, pb *PhilosopherBase) Timer {
+	return pb.Table.Clock.AfterFunc(delay, func() {
+		pb.Table.Send(pb, m)
+	})
 }
@@ -0,0 +1,99 @@
+package shared
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// TraceHeader describes the run a trace records. It is written as the trace's first line
+type TraceHeader struct {
+	Algorithm string   `json:"algorithm"`
+	NPhils    int      `json:"n"`
+	Names     []string `json:"names"`
+	Seed      int64    `json:"seed"`
+}
+
+// NewTraceHeader describes a run of algorithm at Table t
+func NewTraceHeader(t *Table, algorithm string) TraceHeader {
+	return TraceHeader{Algorithm: algorithm, NPhils: t.NPhils, Names: t.Names, Seed: t.Seed}
+}
+
+// TraceSink is an EventSink that writes a TraceHeader, then each Event, as a line of JSON (the JSON Lines format), for
+// analysis after the run. Output is buffered, so the sink must be closed to flush it.
+type TraceSink struct {
+	lock   sync.Mutex
+	w      *bufio.Writer
+	enc    *json.Encoder
+	closer io.Closer
+	closed bool
+	err    error
+}
+
+// NewTraceSink creates a TraceSink writing to w, starting with header h. If w is also an io.Closer, closing the sink
+// closes it
+func NewTraceSink(w io.Writer, h TraceHeader) *TraceSink {
+	bw := bufio.NewWriter(w)
+	s := &TraceSink{w: bw, enc: json.NewEncoder(bw)}
+	if c, ok := w.(io.Closer); ok {
+		s.closer = c
+	}
+	s.err = s.enc.Encode(h)
+	return s
+}
+
+// Record implements the EventSink interface. Events recorded after the sink is closed, or after a write error, are
+// dropped
+func (s *TraceSink) Record(e Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed || s.err != nil {
+		return
+	}
+	s.err = s.enc.Encode(e)
+}
+
+// Close flushes and closes the sink, returning the first error encountered while writing
+func (s *TraceSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return s.err
+	}
+	s.closed = true
+	if err := s.w.Flush(); s.err == nil {
+		s.err = err
+	}
+	if s.closer != nil {
+		if err := s.closer.Close(); s.err == nil {
+			s.err = err
+		}
+	}
+	return s.err
+}
+
+// ReadTrace reads the header and events of a trace written by a TraceSink
+func ReadTrace(r io.Reader) (TraceHeader, []Event, error) {
+	var h TraceHeader
+	events := []Event{}
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&h); err != nil {
+		return h, events, fmt.Errorf("header: %v", err)
+	}
+	if h.NPhils < MinNPhils || len(h.Names) != h.NPhils {
+		return h, events, fmt.Errorf("header: %d philosophers and %d names - is it a trace?", h.NPhils, len(h.Names))
+	}
+	for {
+		var e Event
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			return h, events, nil
+		}
+		if err != nil {
+			return h, events, fmt.Errorf("event %d: %v", len(events)+1, err)
+		}
+		events = append(events, e)
+	}
+}
@@ -0,0 +1,96 @@
+package shared_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The header of a trace of Chandy-Misra with three philosophers
+var header = shared.TraceHeader{Algorithm: "chandymisra", NPhils: 3, Names: []string{"A", "B", "C"}, Seed: 1}
+
+func TestTraceSink(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	sink := shared.NewTraceSink(buffer, header)
+	sink.Record(shared.Event{Time: time.Second, Philosopher: 2, Type: shared.ForkPickedUp, State: philstate.Hungry, Forks: []int{3}, Peer: shared.NoPeer})
+	assert.Empty(t, buffer.String(), "trace is buffered until closed")
+	assert.NoError(t, sink.Close())
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.JSONEq(t, `{"algorithm":"chandymisra","n":3,"names":["A","B","C"],"seed":1}`, lines[0])
+		assert.JSONEq(t, `{"time":1000000000,"philosopher":2,"type":"fork_pickup","state":"Hungry","forks":[3],"peer":-1}`, lines[1])
+	}
+
+	// Events after closing are dropped
+	sink.Record(shared.Event{})
+	assert.Equal(t, 2, bytes.Count(buffer.Bytes(), []byte("\n")))
+}
+
+func TestTraceVirtualRun(t *testing.T) {
+	for name, f := range factories {
+		buffer := &bytes.Buffer{}
+		table, _ := shared.NewTable(5, shared.DiscardOutput{})
+		sink := shared.NewTraceSink(buffer, shared.NewTraceHeader(table, name))
+		clock := shared.NewVirtualClock()
+		table.Clock = clock
+		table.Sinks = append(table.Sinks, sink)
+		table.Seat(f)
+		table.Run(context.Background())
+		clock.Run(time.Hour)
+		assert.NoError(t, sink.Close())
+
+		// Every line is an event, in time order, and each philosopher alternates between thinking, hunger and eating
+		last := time.Duration(0)
+		states := map[int]philstate.Enum{}
+		scanner := bufio.NewScanner(buffer)
+		var h shared.TraceHeader
+		if assert.True(t, scanner.Scan(), name) {
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &h), name)
+			assert.Equal(t, shared.TraceHeader{Algorithm: name, NPhils: 5, Names: table.Names, Seed: table.Seed}, h)
+		}
+		for scanner.Scan() {
+			var e shared.Event
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &e), name)
+			assert.GreaterOrEqual(t, e.Time, last, name)
+			last = e.Time
+			if e.Type == shared.StateChanged {
+				if prev, ok := states[e.Philosopher]; ok && e.State != philstate.Thinking {
+					assert.Equal(t, e.State-1, prev, "%s philosopher %d went from %s to %s", name, e.Philosopher, prev, e.State)
+				}
+				states[e.Philosopher] = e.State
+			}
+		}
+		assert.Len(t, states, 5, name)
+	}
+}
+
+func TestReadTrace(t *testing.T) {
+	h, events, err := shared.ReadTrace(strings.NewReader(`{"algorithm":"chandymisra","n":3,"names":["A","B","C"],"seed":1}
+{"time":1000000000,"philosopher":2,"type":"fork_pickup","state":"Hungry","forks":[3],"peer":-1}
+{"time":2000000000,"philosopher":1,"type":"state","state":"Eating","peer":-1}
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, header, h)
+	assert.Equal(t, []shared.Event{
+		{Time: time.Second, Philosopher: 2, Type: shared.ForkPickedUp, State: philstate.Hungry, Forks: []int{3}, Peer: shared.NoPeer},
+		{Time: 2 * time.Second, Philosopher: 1, Type: shared.StateChanged, State: philstate.Eating, Peer: shared.NoPeer},
+	}, events)
+
+	_, _, err = shared.ReadTrace(strings.NewReader(`{"algorithm":"chandymisra","n":3,"names":["A","B","C"],"seed":1}
+{"time":1,"philosopher":0,"type":"state","state":"Bored","peer":-1}`))
+	assert.EqualError(t, err, `event 1: unknown philosopher state "Bored"`)
+
+	// A trace must start with its header
+	_, _, err = shared.ReadTrace(strings.NewReader(`{"time":1,"philosopher":0,"type":"state","state":"Hungry","peer":-1}`))
+	assert.EqualError(t, err, "header: 0 philosophers and 0 names - is it a trace?")
+	_, _, err = shared.ReadTrace(strings.NewReader(""))
+	assert.EqualError(t, err, "header: EOF")
+}
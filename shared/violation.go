@@ -0,0 +1,160 @@
+package shared
+
+import (
+	"fmt"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"io"
+	"strings"
+	"time"
+)
+
+// ViolationPolicy says what a Table does when an invariant is violated
+type ViolationPolicy int
+
+// Violation policies
+const (
+	// HaltOnViolation freezes the table, so that its state can be examined - see Table.Halted
+	HaltOnViolation ViolationPolicy = iota
+	// LogViolation records the violation, and carries on
+	LogViolation
+	// PanicOnViolation panics
+	PanicOnViolation
+)
+
+var policyNames = []string{"halt", "log", "panic"}
+
+// String implements the Stringer interface
+func (vp ViolationPolicy) String() string {
+	return policyNames[vp]
+}
+
+// ParseViolationPolicy returns the policy with the given name
+func ParseViolationPolicy(name string) (ViolationPolicy, error) {
+	for i, n := range policyNames {
+		if n == name {
+			return ViolationPolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown violation policy %q - use one of %s", name, strings.Join(policyNames, ", "))
+}
+
+// Snapshot is the state of every philosopher and fork at a Table at a moment in time
+type Snapshot struct {
+	Names   []string
+	States  []philstate.Enum
+	Holders []int // Holders[i] is the philosopher holding fork i, or NoPeer
+}
+
+// WriteTo writes the snapshot as a table, one line per philosopher. It implements the io.WriterTo interface
+func (s Snapshot) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for i, name := range s.Names {
+		held := []string{}
+		for f, h := range s.Holders {
+			if h == i {
+				held = append(held, fmt.Sprint(f))
+			}
+		}
+		fmt.Fprintf(&b, "%-20s %3d %-9s forks: %s\n", name, i, s.States[i], strings.Join(held, " "))
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Violation is a broken invariant - something that should never happen, and shows a bug in an algorithm
+type Violation struct {
+	Time        time.Duration
+	Philosopher int   // Who found it
+	Forks       []int // The forks involved
+	Message     string
+	Snapshot    Snapshot // The state of the table when it was found
+}
+
+// String implements the Stringer interface
+func (v Violation) String() string {
+	return fmt.Sprintf("invariant violated at %v by %s (%d): %s", v.Time, v.Snapshot.Names[v.Philosopher],
+		v.Philosopher, v.Message)
+}
+
+// Violate reports an invariant violation found by philosopher id. It is emitted as an InvariantViolated event, passed
+// to Violated, and then the Table's Policy is applied
+func (t *Table) Violate(id int, forks []int, message string) {
+	v := Violation{
+		Time:        t.Clock.Now(),
+		Philosopher: id,
+		Forks:       forks,
+		Message:     message,
+		Snapshot:    t.Snapshot(),
+	}
+	t.violationsLock.Lock()
+	t.violations = append(t.violations, v)
+	t.violationsLock.Unlock()
+
+	t.Emit(Event{
+		Philosopher: id,
+		Type:        InvariantViolated,
+		State:       v.Snapshot.States[id],
+		Forks:       forks,
+		Peer:        NoPeer,
+		Detail:      message,
+	})
+	if t.Violated != nil {
+		t.Violated(v)
+	}
+
+	switch t.Policy {
+	case PanicOnViolation:
+		panic(v.String())
+	case HaltOnViolation:
+		t.halt()
+	}
+}
+
+// Violations returns the invariant violations found so far
+func (t *Table) Violations() []Violation {
+	t.violationsLock.Lock()
+	defer t.violationsLock.Unlock()
+	return append([]Violation{}, t.violations...)
+}
+
+// Halted returns a channel that is closed if the table is halted by a violation
+func (t *Table) Halted() <-chan struct{} {
+	return t.halted
+}
+
+// Recover is deferred by every goroutine running for the Table, to pass any panic to Panicked. If Panicked isn't set
+// the panic carries on as usual
+func (t *Table) Recover() {
+	if t.Panicked == nil {
+		return
+	}
+	if v := recover(); v != nil {
+		t.Panicked(v)
+	}
+}
+
+// Snapshot captures the state of every philosopher and fork
+func (t *Table) Snapshot() Snapshot {
+	s := Snapshot{Names: t.Names, States: make([]philstate.Enum, t.NPhils), Holders: make([]int, t.NPhils)}
+	for i, p := range t.Philosophers {
+		s.States[i] = p.GetState()
+	}
+	for i, f := range t.Forks {
+		s.Holders[i] = NoPeer
+		if h := f.Holder(); h != UnOwned {
+			s.Holders[i] = h
+		}
+	}
+	return s
+}
+
+// Freeze the table: every message is held at the gate, and the clock stops
+func (t *Table) halt() {
+	t.haltOnce.Do(func() {
+		t.Gate.Close()
+		if c, ok := t.Clock.(interface{ Pause() }); ok {
+			c.Pause()
+		}
+		close(t.halted)
+	})
+}
@@ -0,0 +1,85 @@
+package shared_test
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/resourcehierarchy"
+	"github.com/wizardpb/diningphils-go/shared"
+	"testing"
+)
+
+// recorder is an EventSink that keeps every event
+type recorder struct{ events []shared.Event }
+
+func (r *recorder) Record(e shared.Event) { r.events = append(r.events, e) }
+
+func violationTable(policy shared.ViolationPolicy) (*shared.Table, *recorder) {
+	table, _ := shared.NewTable(3, shared.DiscardOutput{})
+	table.Clock = shared.NewVirtualClock()
+	table.Policy = policy
+	events := &recorder{}
+	table.Sinks = append(table.Sinks, events)
+	table.Seat(resourcehierarchy.Factory)
+	return table, events
+}
+
+func TestViolationPolicies(t *testing.T) {
+	table, events := violationTable(shared.LogViolation)
+	reported := []shared.Violation{}
+	table.Violated = func(v shared.Violation) { reported = append(reported, v) }
+	table.Violate(1, []int{1, 2}, "eating without fork 2")
+
+	assert.Len(t, table.Violations(), 1)
+	assert.Equal(t, table.Violations(), reported)
+	v := reported[0]
+	assert.Equal(t, "invariant violated at 0s by Judith Butler (1): eating without fork 2", v.String())
+	assert.Equal(t, []int{1, 2}, v.Forks)
+	assert.Len(t, v.Snapshot.States, 3)
+	assert.Len(t, events.events, 1)
+	assert.Equal(t, shared.InvariantViolated, events.events[0].Type)
+	assert.Equal(t, "eating without fork 2", events.events[0].Detail)
+	assert.False(t, table.Gate.Closed())
+
+	table, _ = violationTable(shared.PanicOnViolation)
+	assert.Panics(t, func() { table.Violate(0, nil, "oops") })
+
+	// A panic on a table's goroutine can be handed over
+	panicked := make(chan interface{}, 1)
+	table.Panicked = func(v interface{}) { panicked <- v }
+	go func() {
+		defer table.Recover()
+		table.Violate(0, nil, "oops")
+	}()
+	assert.Equal(t, "invariant violated at 0s by Hannah Arendt (0): oops", <-panicked)
+
+	table, _ = violationTable(shared.HaltOnViolation)
+	table.Violate(2, nil, "oops")
+	select {
+	case <-table.Halted():
+	default:
+		assert.Fail(t, "table not halted")
+	}
+	assert.True(t, table.Gate.Closed())
+}
+
+func TestParseViolationPolicy(t *testing.T) {
+	for _, p := range []shared.ViolationPolicy{shared.HaltOnViolation, shared.LogViolation, shared.PanicOnViolation} {
+		parsed, err := shared.ParseViolationPolicy(p.String())
+		assert.NoError(t, err)
+		assert.Equal(t, p, parsed)
+	}
+	_, err := shared.ParseViolationPolicy("ignore")
+	assert.EqualError(t, err, `unknown violation policy "ignore" - use one of halt, log, panic`)
+}
+
+func TestSnapshotWriteTo(t *testing.T) {
+	table, _ := violationTable(shared.LogViolation)
+	s := table.Snapshot()
+	s.Holders = []int{0, shared.NoPeer, 0}
+	buffer := &bytes.Buffer{}
+	_, err := s.WriteTo(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hannah Arendt          0 Inactive  forks: 0 2\n"+
+		"Judith Butler          1 Inactive  forks: \n"+
+		"Patricia Churchland    2 Inactive  forks: \n", buffer.String())
+}
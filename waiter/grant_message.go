@@ -0,0 +1,9 @@
+package waiter
+
+// GrantMessage is sent by the Waiter to a hungry Philosopher once both its forks have been picked up on its behalf
+type GrantMessage struct{}
+
+// String implements the Stringer interface
+func (m GrantMessage) String() string {
+	return "Waiter grants permission to eat"
+}
@@ -0,0 +1,62 @@
+package waiter
+
+import (
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+)
+
+// Philosopher implementation
+type Philosopher struct {
+	*shared.PhilosopherBase
+	waiter *Waiter
+}
+
+// Execute implements the Philosopher interface for the Waiter implementation. Ask the waiter for permission when
+// hungry, eat when it is granted, and hand the forks back to the waiter when done
+func (p *Philosopher) Execute(m shared.Message) {
+	switch mt := m.(type) {
+	case shared.NewState:
+		// Update our state value
+		p.SetState(mt.NewState)
+		switch p.State {
+		case philstate.Hungry:
+			p.WriteString("asks the waiter for permission to eat")
+			p.waiter.request(p)
+		case philstate.Thinking:
+			p.Emit(shared.ForkPutDown, p.LeftFork().GetID(), p.RightFork().GetID())
+			p.waiter.release(p)
+			p.StartThinking()
+		}
+	case GrantMessage:
+		// The waiter has already picked up our forks
+		p.Emit(shared.ForkPickedUp, p.LeftFork().GetID(), p.RightFork().GetID())
+		p.Eat()
+	default:
+		panic("unknown message: " + m.String())
+	}
+}
+
+// Eat starts the Philosopher eating. Adds the invariant check
+func (p *Philosopher) Eat() {
+	p.CheckEating()
+	p.PhilosopherBase.Eat()
+}
+
+// Factory is the Philosopher and Fork creation function
+func Factory(t *shared.Table, params shared.CreateParams) (shared.Philosopher, shared.Fork) {
+	return &Philosopher{
+		PhilosopherBase: &shared.PhilosopherBase{
+			Table:       t,
+			ID:          params.ID,
+			Name:        params.Name,
+			State:       philstate.Inactive,
+			ThinkRange:  params.ThinkRange,
+			EatRange:    params.EatRange,
+			Rand:        params.Rand,
+			MessageChan: make(chan shared.Message, 0),
+		},
+		waiter: tableWaiter(t),
+	}, &shared.ForkBase{
+		ID: params.ID,
+	}
+}
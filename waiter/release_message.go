@@ -0,0 +1,15 @@
+package waiter
+
+import "fmt"
+
+// ReleaseMessage is sent to the Waiter by a Philosopher that has finished eating, returning its forks. It is
+// acknowledged by closing done, so that the Philosopher knows its forks are back on the table before it starts thinking
+type ReleaseMessage struct {
+	Philosopher *Philosopher
+	done        chan struct{}
+}
+
+// String implements the Stringer interface
+func (m ReleaseMessage) String() string {
+	return fmt.Sprintf("Philosopher %d returns its forks to the waiter", m.Philosopher.ID)
+}
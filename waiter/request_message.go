@@ -0,0 +1,13 @@
+package waiter
+
+import "fmt"
+
+// RequestMessage is sent to the Waiter by a hungry Philosopher, asking for permission to eat
+type RequestMessage struct {
+	Philosopher *Philosopher
+}
+
+// String implements the Stringer interface
+func (m RequestMessage) String() string {
+	return fmt.Sprintf("Philosopher %d asks the waiter to eat", m.Philosopher.ID)
+}
@@ -0,0 +1,101 @@
+package waiter
+
+import (
+	"github.com/wizardpb/diningphils-go/shared"
+)
+
+// Waiter is the central arbitrator (Dijkstra's 'waiter' or 'butler'). Philosophers ask it for permission to eat, and it
+// grants that permission only when both of the philosopher's forks are free, picking them both up on the philosopher's
+// behalf in a single step. Since only the Waiter ever changes fork ownership, no Philosopher can hold one fork while
+// waiting for the other, and so the table can never deadlock.
+//
+// Requests that cannot be granted immediately are queued, and re-examined in arrival order whenever forks are returned.
+type Waiter struct {
+	table    *shared.Table
+	requests chan shared.Message
+	waiting  []*Philosopher
+}
+
+// The key for the Table value holding the table's Waiter
+type waiterKey struct{}
+
+// tableWaiter returns the single Waiter serving Table t, creating and starting it the first time it is asked for
+func tableWaiter(t *shared.Table) *Waiter {
+	return t.Value(waiterKey{}, func() interface{} {
+		w := &Waiter{table: t, requests: make(chan shared.Message, t.NPhils)}
+		go w.run()
+		return w
+	}).(*Waiter)
+}
+
+// Messages implements the Receiver interface. Requests are sent to the Waiter with Table.Send, just like messages to
+// philosophers, so they count as work for the table's Clock until they have been handled
+func (w *Waiter) Messages() chan shared.Message {
+	return w.requests
+}
+
+// request asks the Waiter for permission to eat. The answer arrives later as a GrantMessage
+func (w *Waiter) request(p *Philosopher) {
+	w.table.Send(w, RequestMessage{Philosopher: p})
+}
+
+// release returns both of p's forks to the Waiter, and waits until they are back on the table
+func (w *Waiter) release(p *Philosopher) {
+	done := make(chan struct{})
+	w.table.Send(w, ReleaseMessage{Philosopher: p, done: done})
+	<-done
+}
+
+// String implements the Stringer interface
+func (w *Waiter) String() string {
+	return "the waiter"
+}
+
+// run is the Waiter's main loop - handle each request, then see who can now be served. Requests pass through the
+// table's Gate, just like a philosopher's messages, so that pausing the table pauses the Waiter too. The Waiter goes
+// home once every philosopher has stopped
+func (w *Waiter) run() {
+	defer w.table.Recover()
+	for {
+		var m shared.Message
+		select {
+		case m = <-w.requests:
+		case <-w.table.Stopped():
+			return
+		}
+		w.table.Gate.Pass(w.table.Clock, w, m, func() { w.handle(m) })
+		w.table.Clock.Done()
+	}
+}
+
+// handle carries out request m
+func (w *Waiter) handle(m shared.Message) {
+	switch r := m.(type) {
+	case RequestMessage:
+		w.waiting = append(w.waiting, r.Philosopher)
+	case ReleaseMessage:
+		p := r.Philosopher
+		for _, f := range []shared.Fork{p.LeftFork(), p.RightFork()} {
+			p.Check(f.IsHeldBy(p.ID), []int{f.GetID()}, "waiter frees fork %d not held by %d", f.GetID(), p.ID)
+			f.SetFree()
+		}
+		close(r.done)
+	}
+	w.serve()
+}
+
+// serve grants permission to every waiting Philosopher whose forks are both free, oldest request first
+func (w *Waiter) serve() {
+	stillWaiting := w.waiting[:0]
+	for _, p := range w.waiting {
+		if p.LeftFork().IsHeld() || p.RightFork().IsHeld() {
+			stillWaiting = append(stillWaiting, p)
+			continue
+		}
+		// Pick up both forks at once on the Philosopher's behalf
+		p.LeftFork().SetHolder(p.ID)
+		p.RightFork().SetHolder(p.ID)
+		w.table.Send(p, GrantMessage{})
+	}
+	w.waiting = stillWaiting
+}
@@ -0,0 +1,93 @@
+package waiter_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/wizardpb/diningphils-go/shared"
+	"github.com/wizardpb/diningphils-go/shared/philstate"
+	"github.com/wizardpb/diningphils-go/waiter"
+	"testing"
+	"time"
+)
+
+func TestMessages(t *testing.T) {
+	table, _ := shared.NewTable(5, shared.DiscardOutput{})
+	clock := shared.NewVirtualClock()
+	stats := shared.NewStats(table.Names)
+	table.Clock = clock
+	table.Seed = 1
+	table.Sinks = append(table.Sinks, stats)
+	table.Seat(waiter.Factory)
+	table.Run(context.Background())
+	assert.Equal(t, time.Hour, clock.Run(time.Hour), "the waiter stalled")
+
+	// Every meal takes a request, a grant and a release, all sent through the table. Only the last few requests can
+	// still be waiting for an answer, or a meal still going on
+	meals := 0
+	for _, p := range stats.Report().Philosophers {
+		meals += p.Meals
+	}
+	counts := table.MessageCounts()
+	requests, grants, releases := counts["waiter.RequestMessage"], counts["waiter.GrantMessage"],
+		counts["waiter.ReleaseMessage"]
+	assert.Greater(t, meals, 100)
+	assert.Equal(t, meals, grants)
+	assert.LessOrEqual(t, releases, grants)
+	assert.LessOrEqual(t, grants, requests)
+	assert.LessOrEqual(t, requests-releases, table.NPhils)
+}
+
+func TestPause(t *testing.T) {
+	table, _ := shared.NewTable(2, shared.DiscardOutput{})
+	table.ThinkRange = shared.TimeRange{Min: 1, Max: 5, Unit: time.Millisecond}
+	table.EatRange = shared.TimeRange{Min: 1, Max: 5, Unit: time.Millisecond}
+	clock := table.Clock.(*shared.RealClock)
+	executed := make(chan string, 10)
+	table.Gate.Executed = func(r shared.Receiver, m shared.Message) { executed <- fmt.Sprint(r) + ": " + m.String() }
+	next := func() string {
+		select {
+		case s := <-executed:
+			return s
+		case <-time.After(time.Second):
+			return "nothing executed"
+		}
+	}
+
+	// Freeze the table before it starts, and step the clock to make someone hungry
+	clock.Pause()
+	table.Gate.Close()
+	table.Seat(waiter.Factory)
+	ctx, stop := context.WithCancel(context.Background())
+	table.Run(ctx)
+	assert.Eventually(t, clock.Step, time.Second, time.Millisecond)
+	assert.Eventually(t, table.Gate.Step, time.Second, time.Millisecond)
+	assert.Contains(t, next(), "NewState: Hungry")
+
+	// The request is held at the gate like any other message, so the waiter doesn't hand out the forks until it is
+	// stepped through
+	time.Sleep(10 * time.Millisecond)
+	for _, f := range table.Forks {
+		assert.False(t, f.IsHeld())
+	}
+	assert.Eventually(t, table.Gate.Step, time.Second, time.Millisecond)
+	assert.Regexp(t, `^the waiter: Philosopher \d asks the waiter to eat$`, next())
+	for _, f := range table.Forks {
+		assert.True(t, f.IsHeld())
+	}
+
+	// The grant is held too
+	time.Sleep(10 * time.Millisecond)
+	for _, p := range table.Philosophers {
+		assert.NotEqual(t, philstate.Eating, p.GetState())
+	}
+	assert.Eventually(t, table.Gate.Step, time.Second, time.Millisecond)
+	assert.Contains(t, next(), "Waiter grants permission to eat")
+
+	table.Gate.Open()
+	clock.Resume()
+	stop()
+	wait, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, table.Wait(wait))
+}